@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/report"
+)
+
+// runExport fetches the costs once and writes them as CSV or Parquet. Costs
+// of the accounts fetched successfully are written even if others failed.
+func runExport(ctx context.Context, args []string) error {
+	logger := initLogger(true)
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to config file")
+	overrides := addSetFlag(flags)
+	format := flags.String("format", report.FormatCSV, "output format: csv or parquet")
+	output := flags.String("output", "-", "output file, - for stdout")
+	asOf := addAsOfFlag(flags)
+	_ = flags.Parse(args)
+
+	if *format != report.FormatCSV && *format != report.FormatParquet {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	clock := asOf.clock()
+
+	cfg, err := loadConfig(*configPath, overrides)
+	if err != nil {
+		return err
+	}
+
+	coll, err := collector.New(cfg, clock, logger.With("component", "collector"))
+	if err != nil {
+		return fmt.Errorf("creating collector: %w", err)
+	}
+	refreshErr := coll.Refresh(ctx)
+
+	if err := writeOutput(*output, func(w io.Writer) error {
+		return report.Write(w, *format, coll.Costs())
+	}); err != nil {
+		return err
+	}
+	slog.Info("costs exported", "format", *format, "output", *output)
+
+	if refreshErr != nil {
+		return fmt.Errorf("refresh: %w", refreshErr)
+	}
+	return nil
+}
+
+// writeOutput calls write with stdout for "-" or the created file otherwise
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing output file: %w", err)
+	}
+	return nil
+}
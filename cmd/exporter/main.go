@@ -3,15 +3,22 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/exporter"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
 )
 
+const defaultConfigPath = "/etc/aws-cost-exporter/config.yaml"
+
 func main() {
 	// Graceful shutdown
 	ctx, cancel := signal.NotifyContext(
@@ -19,31 +26,209 @@ func main() {
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	// Init logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	// Without subcommand, run the exporter
+	args := os.Args[1:]
+	command := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "":
+		err = runServe(ctx, args)
+	case "export":
+		err = runExport(ctx, args)
+	case "once":
+		err = runOnce(ctx, args)
+	case "plan":
+		err = runPlan(args)
+	case "query":
+		err = runQuery(ctx, args)
+	case "validate":
+		err = runValidate(args)
+	case "version":
+		printVersion()
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+
+	if err != nil {
+		slog.Error("exporter error", "error", err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// initLogger sets the default logger. Commands writing data to stdout log to
+// stderr.
+func initLogger(stderr bool) *slog.Logger {
+	out := os.Stdout
+	if stderr {
+		out = os.Stderr
+	}
+	logger := slog.New(slog.NewJSONHandler(out, nil))
 	slog.SetDefault(logger)
+	return logger
+}
+
+func runServe(ctx context.Context, args []string) error {
+	logger := initLogger(false)
 
 	// Parse flags
-	configPath := flag.String("config", "/etc/aws-cost-exporter/config.yaml", "path to config file")
-	flag.Parse()
+	flags := flag.NewFlagSet("aws-cost-exporter", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to config file")
+	overrides := addSetFlag(flags)
+	once := flags.Bool("once", false, "refresh once, write the configured outputs (pushgateway, remote_write, otlp, textfile) and exit, see the once command to print the metrics instead")
+	asOf := addAsOfFlag(flags)
+	webConfigFile := flags.String("web.config.file", "", "path to the web config file enabling TLS and authentication, overrides web_config_file")
+	listenAddress := flags.String("web.listen-address", "", "address to listen on, overrides server.listen_address")
+	systemdSocket := flags.Bool("web.systemd-socket", false, "use systemd socket activation listeners instead of the listen address")
+	showVersion := flags.Bool("version", false, "print version information and exit")
+	dryRun := flags.Bool("dry-run", false, "print the Cost Explorer requests of a refresh and their estimated cost, then exit")
+	_ = flags.Parse(args)
+
+	if *dryRun {
+		// Keep stdout for the plan
+		logger = initLogger(true)
+	}
 
-	// Load config
-	cfg, err := config.Load(*configPath)
+	if *showVersion {
+		printVersion()
+		return nil
+	}
+	slog.Info("aws-cost-exporter", "version", version, "revision", revision, "build_date", buildDate)
+
+	clock := asOf.clock()
+
+	// Load config, flags override the file and environment variables
+	if *webConfigFile != "" {
+		overrides["web_config_file"] = *webConfigFile
+	}
+	if *listenAddress != "" {
+		overrides["server.listen_address"] = *listenAddress
+	}
+	if *systemdSocket {
+		overrides["server.systemd_socket"] = true
+	}
+	cfg, err := loadConfig(*configPath, overrides)
 	if err != nil {
-		slog.Error("failed to load config file", "error", err)
-		os.Exit(1)
+		return err
+	}
+
+	if *dryRun {
+		return writePlan(os.Stdout, "text", cfg, clock)
 	}
 
 	// Create exporter
-	exp, err := exporter.New(cfg, logger)
+	exp, err := exporter.New(cfg, clock, logger)
 	if err != nil {
-		slog.Error("failed to create exporter", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("creating exporter: %w", err)
+	}
+
+	if err := exp.RegisterBuildInfo(exporter.BuildInfo{
+		Version:  version,
+		Revision: revision,
+	}); err != nil {
+		return err
+	}
+
+	if *once {
+		return exp.RunOnce(ctx)
 	}
 
 	// Run exporter
-	if err := exp.Run(ctx); err != nil {
-		slog.Error("exporter error", "error", err)
-		os.Exit(1)
+	return exp.Run(ctx)
+}
+
+// loadConfig loads the config file with the overrides of the command line
+// flags and logs its warnings
+func loadConfig(path string, overrides map[string]any) (*config.Config, error) {
+	cfg, err := config.LoadWithOverrides(path, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("loading config file: %w", err)
+	}
+	for _, warning := range cfg.Warnings() {
+		slog.Warn("config warning", "warning", warning)
+	}
+	return cfg, nil
+}
+
+// overridesFlag collects the repeated --set flags by config key
+type overridesFlag map[string]any
+
+func (f overridesFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for key, value := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f overridesFlag) Set(s string) error {
+	key, value, err := config.ParseOverride(s)
+	if err != nil {
+		return err
+	}
+	f[key] = value
+	return nil
+}
+
+// addSetFlag defines the --set flag overriding the config file and the
+// environment variables
+func addSetFlag(flags *flag.FlagSet) overridesFlag {
+	overrides := make(overridesFlag)
+	flags.Var(overrides, "set", "override a config key holding a single value, as key=value (e.g. polling_interval=1h), can be repeated")
+	return overrides
+}
+
+// asOfFlag is the --as-of flag, computing the query periods as of a fixed
+// time instead of now
+type asOfFlag struct {
+	t time.Time
+}
+
+// addAsOfFlag defines the --as-of flag of the commands querying costs
+func addAsOfFlag(flags *flag.FlagSet) *asOfFlag {
+	f := &asOfFlag{}
+	flags.Var(f, "as-of", "compute query periods as of this date (YYYY-MM-DD or RFC3339) instead of now")
+	return f
+}
+
+func (f *asOfFlag) String() string {
+	if f.t.IsZero() {
+		return ""
+	}
+	return f.t.Format(time.RFC3339)
+}
+
+func (f *asOfFlag) Set(s string) error {
+	t, err := parseAsOf(s)
+	if err != nil {
+		return err
+	}
+	f.t = t
+	return nil
+}
+
+// clock returns the system clock, or a fixed clock when the flag is set
+func (f *asOfFlag) clock() timeutil.Clock {
+	if f.t.IsZero() {
+		return timeutil.SystemClock{}
+	}
+	slog.Info("using fixed clock", "as_of", f.t)
+	return timeutil.FixedClock(f.t)
+}
+
+// parseAsOf parses a --as-of value, either a date or an RFC3339 timestamp.
+func parseAsOf(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %q: expected YYYY-MM-DD or RFC3339", value)
 	}
+	return t, nil
 }
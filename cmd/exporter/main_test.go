@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+exporter_port: 9100
+polling_interval: 1h
+target_aws_accounts:
+  - account_id: "123456789012"
+    assumed_role_name: cost-exporter
+metrics:
+  - metric_name: aws_daily_cost
+    granularity: DAILY
+    metric_type: UnblendedCost
+`
+
+func parseSetFlags(t *testing.T, args ...string) (overridesFlag, error) {
+	t.Helper()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	overrides := addSetFlag(flags)
+	return overrides, flags.Parse(args)
+}
+
+func TestSetFlagPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_COST_EXPORTER_EXPORTER_PORT", "9200")
+	t.Setenv("AWS_COST_EXPORTER_POLLING_INTERVAL", "2h")
+
+	overrides, err := parseSetFlags(t,
+		"--set", "exporter_port=9300",
+		"--set", "exporter_metrics.go_collector=false",
+	)
+	if err != nil {
+		t.Fatalf("parsing flags: %v", err)
+	}
+	cfg, err := loadConfig(path, overrides)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	// The flag beats the file and the environment
+	if cfg.ExporterPort != 9300 {
+		t.Errorf("exporter_port = %d, want the --set value 9300", cfg.ExporterPort)
+	}
+	if cfg.PollingInterval != 2*time.Hour {
+		t.Errorf("polling_interval = %s, want the environment value 2h", cfg.PollingInterval)
+	}
+	if cfg.ExporterMetrics.GoCollector {
+		t.Error("exporter_metrics.go_collector = true, want the --set value false")
+	}
+}
+
+func TestSetFlagInvalid(t *testing.T) {
+	for _, arg := range []string{"exporter_port", "unknown=1", "metrics=aws_daily_cost", "server=x"} {
+		t.Run(arg, func(t *testing.T) {
+			if _, err := parseSetFlags(t, "--set", arg); err == nil {
+				t.Errorf("--set %s succeeded, want error", arg)
+			}
+		})
+	}
+}
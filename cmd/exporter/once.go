@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+)
+
+// runOnce fetches the costs once and writes the metrics in the Prometheus
+// text or OpenMetrics format. Metrics of the accounts fetched successfully
+// are written even if others failed, but the command fails. Unlike the
+// --once flag of the exporter, the configured outputs are not written.
+func runOnce(ctx context.Context, args []string) error {
+	logger := initLogger(true)
+
+	flags := flag.NewFlagSet("once", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to config file")
+	overrides := addSetFlag(flags)
+	format := flags.String("format", "text", "exposition format: text or openmetrics")
+	output := flags.String("output", "-", "output file, - for stdout")
+	asOf := addAsOfFlag(flags)
+	_ = flags.Parse(args)
+
+	var expFormat expfmt.Format
+	switch *format {
+	case "text":
+		expFormat = expfmt.NewFormat(expfmt.TypeTextPlain)
+	case "openmetrics":
+		expFormat = expfmt.NewFormat(expfmt.TypeOpenMetrics)
+	default:
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	clock := asOf.clock()
+
+	cfg, err := loadConfig(*configPath, overrides)
+	if err != nil {
+		return err
+	}
+
+	coll, err := collector.New(cfg, clock, logger.With("component", "collector"))
+	if err != nil {
+		return fmt.Errorf("creating collector: %w", err)
+	}
+	refreshErr := coll.Refresh(ctx)
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(coll); err != nil {
+		return fmt.Errorf("registering collector: %w", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+
+	if err := writeOutput(*output, func(w io.Writer) error {
+		enc := expfmt.NewEncoder(w, expFormat)
+		for _, family := range families {
+			if err := enc.Encode(family); err != nil {
+				return fmt.Errorf("encoding metrics: %w", err)
+			}
+		}
+		if closer, ok := enc.(expfmt.Closer); ok {
+			return closer.Close()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	slog.Info("metrics written", "format", *format, "output", *output)
+
+	if refreshErr != nil {
+		return fmt.Errorf("refresh: %w", refreshErr)
+	}
+	return nil
+}
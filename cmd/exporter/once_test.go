@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// onceConfig queries the month to date, which is empty and not queried on
+// the 1st, and writes a textfile output
+const onceConfig = `
+target_aws_accounts:
+  - account_id: "123456789012"
+    assumed_role_name: cost-exporter
+metrics:
+  - metric_name: aws_monthly_cost
+    granularity: MONTHLY
+    metric_type: UnblendedCost
+    empty_period_policy: zero
+textfile:
+  path: %TEXTFILE%
+`
+
+func writeOnceConfig(t *testing.T) (configPath, textfilePath string) {
+	t.Helper()
+	dir := t.TempDir()
+	textfilePath = filepath.Join(dir, "aws_cost.prom")
+	configPath = filepath.Join(dir, "config.yaml")
+	content := strings.ReplaceAll(onceConfig, "%TEXTFILE%", textfilePath)
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return configPath, textfilePath
+}
+
+func TestOnceCommandPrintsMetrics(t *testing.T) {
+	configPath, textfilePath := writeOnceConfig(t)
+	output := filepath.Join(t.TempDir(), "metrics.prom")
+
+	err := runOnce(context.Background(), []string{"--config", configPath, "--as-of", "2025-03-01", "--output", output})
+	if err != nil {
+		t.Fatalf("runOnce() error = %v", err)
+	}
+
+	printed, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if !strings.Contains(string(printed), `aws_monthly_cost{account_id="123456789012"`) {
+		t.Errorf("output = %s, want aws_monthly_cost", printed)
+	}
+	// The configured outputs are left to the --once flag
+	if _, err := os.Stat(textfilePath); !os.IsNotExist(err) {
+		t.Errorf("textfile output was written, stat error = %v", err)
+	}
+}
+
+func TestOnceFlagWritesOutputs(t *testing.T) {
+	configPath, textfilePath := writeOnceConfig(t)
+
+	if err := runServe(context.Background(), []string{"--config", configPath, "--as-of", "2025-03-01", "--once"}); err != nil {
+		t.Fatalf("runServe() error = %v", err)
+	}
+
+	written, err := os.ReadFile(textfilePath)
+	if err != nil {
+		t.Fatalf("reading textfile output: %v", err)
+	}
+	if !strings.Contains(string(written), `aws_monthly_cost{account_id="123456789012"`) {
+		t.Errorf("textfile output = %s, want aws_monthly_cost", written)
+	}
+}
+
+func TestAsOfFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    time.Time
+		wantErr bool
+	}{
+		{args: []string{"--as-of", "2025-03-01"}, want: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{args: []string{"--as-of", "2025-03-01T10:30:00Z"}, want: time.Date(2025, time.March, 1, 10, 30, 0, 0, time.UTC)},
+		{args: []string{"--as-of", "03/01/2025"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			asOf := addAsOfFlag(flags)
+			err := flags.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsing %v error = %v, want error %v", tt.args, err, tt.wantErr)
+			}
+			if !tt.wantErr && !asOf.clock().Now().Equal(tt.want) {
+				t.Errorf("clock = %s, want %s", asOf.clock().Now(), tt.want)
+			}
+		})
+	}
+
+	// Without the flag, periods are computed as of now
+	asOf := addAsOfFlag(flag.NewFlagSet("test", flag.ContinueOnError))
+	if _, ok := asOf.clock().(timeutil.SystemClock); !ok {
+		t.Errorf("clock = %T, want the system clock", asOf.clock())
+	}
+}
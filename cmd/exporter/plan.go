@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// costPerRequest is the Cost Explorer API price in USD
+const costPerRequest = 0.01
+
+// planOutput is the JSON document of a plan
+type planOutput struct {
+	Requests                []planRequest `json:"requests"`
+	RequestsPerPoll         int           `json:"requests_per_poll"`
+	PollingInterval         string        `json:"polling_interval"`
+	PollsPerMonth           float64       `json:"polls_per_month"`
+	RequestsPerMonth        float64       `json:"requests_per_month"`
+	EstimatedMonthlyCostUSD float64       `json:"estimated_monthly_cost_usd"`
+}
+
+type planRequest struct {
+	AccountID string `json:"account_id"`
+	Metric    string `json:"metric"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	Input     any    `json:"input"`
+}
+
+// runPlan prints the Cost Explorer requests of a refresh and their estimated
+// cost without contacting AWS.
+func runPlan(args []string) error {
+	initLogger(true)
+
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to config file")
+	overrides := addSetFlag(flags)
+	format := flags.String("format", "text", "output format: text or json")
+	asOf := addAsOfFlag(flags)
+	_ = flags.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	clock := asOf.clock()
+
+	cfg, err := loadConfig(*configPath, overrides)
+	if err != nil {
+		return err
+	}
+
+	return writePlan(os.Stdout, *format, cfg, clock)
+}
+
+func writePlan(w io.Writer, format string, cfg *config.Config, clock timeutil.Clock) error {
+	plan, err := buildPlan(cfg, clock)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+
+	for _, req := range plan.Requests {
+		status := ""
+		if req.Skipped {
+			status = " (skipped, empty period)"
+		}
+		input, err := json.MarshalIndent(req.Input, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		fmt.Fprintf(w, "account %s, metric %s%s\n%s\n\n", req.AccountID, req.Metric, status, input)
+	}
+
+	fmt.Fprintf(w, "%d requests per poll, polling every %s (%.1f polls per month)\n",
+		plan.RequestsPerPoll, plan.PollingInterval, plan.PollsPerMonth)
+	fmt.Fprintf(w, "estimated %.0f requests per month, $%.2f per month at $%.2f per request, excluding pagination and manual refreshes\n",
+		plan.RequestsPerMonth, plan.EstimatedMonthlyCostUSD, costPerRequest)
+	return nil
+}
+
+func buildPlan(cfg *config.Config, clock timeutil.Clock) (planOutput, error) {
+	plan := planOutput{PollingInterval: cfg.PollingInterval.String()}
+
+	for _, req := range collector.Plan(cfg, clock) {
+		input, err := compactJSON(req.Input)
+		if err != nil {
+			return planOutput{}, fmt.Errorf("encoding request: %w", err)
+		}
+		plan.Requests = append(plan.Requests, planRequest{
+			AccountID: req.AccountID,
+			Metric:    req.Metric,
+			Skipped:   req.Skipped,
+			Input:     input,
+		})
+		if !req.Skipped {
+			plan.RequestsPerPoll++
+		}
+	}
+
+	if cfg.PollingInterval > 0 {
+		plan.PollsPerMonth = float64(30*24*time.Hour) / float64(cfg.PollingInterval)
+	}
+	plan.RequestsPerMonth = float64(plan.RequestsPerPoll) * plan.PollsPerMonth
+	plan.EstimatedMonthlyCostUSD = plan.RequestsPerMonth * costPerRequest
+	return plan, nil
+}
+
+// compactJSON returns v as generic JSON without its null fields, which are
+// the unset fields of the SDK request.
+func compactJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return dropNulls(generic), nil
+}
+
+func dropNulls(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if value == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = dropNulls(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/aws"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+const (
+	queryFormatTable = "table"
+	queryFormatJSON  = "json"
+	queryFormatCSV   = "csv"
+)
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// queryRow is one group of a query result
+type queryRow struct {
+	Start     string            `json:"start,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Amount    float64           `json:"amount"`
+	Unit      string            `json:"unit"`
+	Estimated bool              `json:"estimated"`
+}
+
+// queryOutput is the JSON document of a query
+type queryOutput struct {
+	AccountID   string     `json:"account_id"`
+	Metric      string     `json:"metric,omitempty"`
+	Granularity string     `json:"granularity"`
+	MetricType  string     `json:"metric_type"`
+	Start       string     `json:"start"`
+	End         string     `json:"end"`
+	Rows        []queryRow `json:"rows"`
+}
+
+// runQuery runs a one-off Cost Explorer query for an account of the config.
+// The query is a metric of the config, optionally overridden by the inline
+// flags, or defined by the inline flags only.
+func runQuery(ctx context.Context, args []string) error {
+	initLogger(true)
+
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to config file")
+	overrides := addSetFlag(flags)
+	accountID := flags.String("account", "", "account_id of a target_aws_accounts entry (required)")
+	metricName := flags.String("metric", "", "metric_name of a configured metric to run")
+	granularity := flags.String("granularity", "DAILY", "HOURLY, DAILY or MONTHLY")
+	period := flags.String("period", "", "query period, as the metric period setting")
+	metricType := flags.String("metric-type", "UnblendedCost", "Cost Explorer metric, such as UnblendedCost or AmortizedCost")
+	delay := flags.Int("data-delay-days", 0, "days to shift the period back")
+	recordTypes := flags.String("record-types", "", "comma separated record types, Usage by default")
+	var groupBy, tagFilters stringList
+	flags.Var(&groupBy, "group-by", "group by TYPE:KEY, such as DIMENSION:SERVICE or TAG:team, may be repeated")
+	flags.Var(&tagFilters, "tag-filter", "filter on KEY=VALUE[,VALUE...], may be repeated")
+	format := flags.String("format", queryFormatTable, "output format: table, json or csv")
+	output := flags.String("output", "-", "output file, - for stdout")
+	asOf := addAsOfFlag(flags)
+	_ = flags.Parse(args)
+
+	if *format != queryFormatTable && *format != queryFormatJSON && *format != queryFormatCSV {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+	if *accountID == "" {
+		return fmt.Errorf("--account is required")
+	}
+
+	clock := asOf.clock()
+
+	cfg, err := loadConfig(*configPath, overrides)
+	if err != nil {
+		return err
+	}
+
+	var account *config.AWSAccount
+	for i := range cfg.TargetAWSAccounts {
+		if cfg.TargetAWSAccounts[i].AccountId == *accountID {
+			account = &cfg.TargetAWSAccounts[i]
+		}
+	}
+	if account == nil {
+		return fmt.Errorf("account %s is not in target_aws_accounts", *accountID)
+	}
+
+	var metricCfg config.MetricConfig
+	if *metricName != "" {
+		found := false
+		for _, m := range cfg.Metrics {
+			if m.MetricName == *metricName {
+				metricCfg, found = account.Override(m), true
+			}
+		}
+		if !found {
+			return fmt.Errorf("metric %s is not in metrics", *metricName)
+		}
+	}
+
+	// Inline flags override the configured metric, or define the query
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *metricName == "" || set["granularity"] {
+		metricCfg.Granularity = strings.ToUpper(*granularity)
+	}
+	if *metricName == "" || set["metric-type"] {
+		metricCfg.MetricType = *metricType
+	}
+	if set["period"] {
+		metricCfg.Period = *period
+	}
+	if set["data-delay-days"] {
+		metricCfg.DataDelayDays = *delay
+	}
+	if set["record-types"] {
+		metricCfg.RecordTypes = strings.Split(*recordTypes, ",")
+	}
+	if set["group-by"] {
+		metricCfg.GroupBy, err = parseGroupBy(groupBy)
+		if err != nil {
+			return err
+		}
+	}
+	if set["tag-filter"] {
+		metricCfg.TagFilters, err = parseTagFilters(tagFilters)
+		if err != nil {
+			return err
+		}
+	}
+	if metricCfg.MetricName == "" {
+		metricCfg.MetricName = "query"
+	}
+	if err := config.ValidateMetric(&metricCfg); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+
+	// Cost Explorer rejects empty windows, such as month to date on the 1st
+	query := collector.BuildQuery(clock, &metricCfg)
+	window := timeutil.Period{Start: query.StartDate, End: query.EndDate}
+	if window.Empty() {
+		return fmt.Errorf("query period from %s to %s is empty, set another --period or --as-of",
+			window.Start.Format(time.DateOnly), window.End.Format(time.DateOnly))
+	}
+
+	client, err := aws.NewCostExplorerClient(cfg, account.AccountId, account.AssumedRoleName)
+	if err != nil {
+		return fmt.Errorf("creating AWS client: %w", err)
+	}
+
+	result, err := client.GetCostAndUsage(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	out := queryOutput{
+		AccountID:   account.AccountId,
+		Metric:      *metricName,
+		Granularity: query.Granularity,
+		MetricType:  query.MetricType,
+		Start:       query.StartDate.Format(time.RFC3339),
+		End:         query.EndDate.Format(time.RFC3339),
+		Rows:        queryRows(&metricCfg, result),
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		switch *format {
+		case queryFormatJSON:
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		case queryFormatCSV:
+			return writeQueryCSV(w, &metricCfg, out)
+		}
+		return writeQueryTable(w, &metricCfg, out)
+	})
+}
+
+// parseGroupBy parses TYPE:KEY group-by flags. Groups are labelled after
+// their lowercased key.
+func parseGroupBy(values []string) (*config.GroupByConfig, error) {
+	groupBy := &config.GroupByConfig{Enabled: true}
+	for _, value := range values {
+		typ, key, ok := strings.Cut(value, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --group-by %q: expected TYPE:KEY", value)
+		}
+		groupBy.Groups = append(groupBy.Groups, config.GroupConfig{
+			Type:      strings.ToUpper(typ),
+			Key:       key,
+			LabelName: strings.ToLower(key),
+		})
+	}
+	return groupBy, nil
+}
+
+// parseTagFilters parses KEY=VALUE[,VALUE...] tag filter flags
+func parseTagFilters(values []string) ([]config.TagFilter, error) {
+	var filters []config.TagFilter
+	for _, value := range values {
+		key, tagValues, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --tag-filter %q: expected KEY=VALUE[,VALUE...]", value)
+		}
+		filters = append(filters, config.TagFilter{
+			TagKey:    key,
+			TagValues: strings.Split(tagValues, ","),
+		})
+	}
+	return filters, nil
+}
+
+// queryRows converts a query result to rows. Ungrouped results have a single
+// row with the total, or one row per hour for HOURLY queries.
+func queryRows(metricCfg *config.MetricConfig, result *aws.CostResult) []queryRow {
+	if len(result.Groups) == 0 {
+		return []queryRow{{Amount: result.Total, Unit: result.Unit, Estimated: result.Estimated}}
+	}
+
+	// Rows are labelled like the exported series, with the tag values and
+	// aliases of the groups
+	labels := collector.GroupLabelNames(metricCfg)
+	rows := make([]queryRow, 0, len(result.Groups))
+	for _, group := range result.Groups {
+		row := queryRow{
+			Amount:    group.Amount,
+			Unit:      group.Unit,
+			Estimated: group.Estimated,
+		}
+		if !group.Start.IsZero() {
+			row.Start = group.Start.Format(time.RFC3339)
+		}
+		if len(labels) > 0 {
+			row.Labels = make(map[string]string, len(labels))
+			for i, value := range collector.GroupLabelValues(metricCfg, group.Keys) {
+				row.Labels[labels[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// queryColumns returns the header of the table and CSV outputs
+func queryColumns(metricCfg *config.MetricConfig) []string {
+	columns := []string{"start"}
+	columns = append(columns, collector.GroupLabelNames(metricCfg)...)
+	return append(columns, "amount", "unit", "estimated")
+}
+
+func queryRecord(metricCfg *config.MetricConfig, out queryOutput, row queryRow) []string {
+	start := row.Start
+	if start == "" {
+		start = out.Start
+	}
+	record := []string{start}
+	for _, label := range collector.GroupLabelNames(metricCfg) {
+		record = append(record, row.Labels[label])
+	}
+	return append(record,
+		strconv.FormatFloat(row.Amount, 'f', -1, 64),
+		row.Unit,
+		strconv.FormatBool(row.Estimated))
+}
+
+func writeQueryCSV(w io.Writer, metricCfg *config.MetricConfig, out queryOutput) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(queryColumns(metricCfg)); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	for _, row := range out.Rows {
+		if err := cw.Write(queryRecord(metricCfg, out, row)); err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	return nil
+}
+
+func writeQueryTable(w io.Writer, metricCfg *config.MetricConfig, out queryOutput) error {
+	fmt.Fprintf(w, "account %s, %s %s from %s to %s\n\n",
+		out.AccountID, out.Granularity, out.MetricType, out.Start, out.End)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(queryColumns(metricCfg), "\t")))
+	for _, row := range out.Rows {
+		fmt.Fprintln(tw, strings.Join(queryRecord(metricCfg, out, row), "\t"))
+	}
+	return tw.Flush()
+}
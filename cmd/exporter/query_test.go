@@ -0,0 +1,54 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/aws"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+func TestQueryRowsLabels(t *testing.T) {
+	metricCfg := &config.MetricConfig{
+		GroupBy: &config.GroupByConfig{
+			Enabled: true,
+			Groups: []config.GroupConfig{
+				{
+					Type:      "DIMENSION",
+					Key:       "SERVICE",
+					LabelName: "service",
+					Alias: &config.AliasConfig{
+						LabelName: "service_short",
+						Map:       map[string]string{"Amazon Elastic Compute Cloud - Compute": "EC2"},
+					},
+				},
+				{Type: "TAG", Key: "team", LabelName: "team"},
+			},
+		},
+	}
+	result := &aws.CostResult{Groups: []aws.CostGroup{
+		{Keys: []string{"Amazon Elastic Compute Cloud - Compute", "team$platform"}, Amount: 12.5, Unit: "USD"},
+		{Keys: []string{"AWS Lambda", "team$"}, Amount: 1, Unit: "USD"},
+	}}
+
+	rows := queryRows(metricCfg, result)
+	want := []map[string]string{
+		{"service": "Amazon Elastic Compute Cloud - Compute", "service_short": "EC2", "team": "platform"},
+		{"service": "AWS Lambda", "service_short": "AWS Lambda", "team": ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("queryRows() = %v, want %d rows", rows, len(want))
+	}
+	for i, row := range rows {
+		if !maps.Equal(row.Labels, want[i]) {
+			t.Errorf("row %d labels = %v, want %v", i, row.Labels, want[i])
+		}
+	}
+
+	// Table and CSV columns follow the same labels
+	wantColumns := []string{"start", "service", "service_short", "team", "amount", "unit", "estimated"}
+	if got := queryColumns(metricCfg); !slices.Equal(got, wantColumns) {
+		t.Errorf("queryColumns() = %v, want %v", got, wantColumns)
+	}
+}
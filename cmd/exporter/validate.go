@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// runValidate checks the config file and prints all its problems, one per
+// line prefixed by its YAML path, followed by the resolved metrics. It fails
+// if any problem is found.
+func runValidate(args []string) error {
+	initLogger(true)
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to config file")
+	_ = flags.Parse(args)
+
+	cfg, problems, err := config.Check(*configPath)
+	if err != nil {
+		return fmt.Errorf("checking config file: %w", err)
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stdout, "error: %s\n", problem)
+	}
+	if cfg != nil {
+		for _, warning := range cfg.Warnings() {
+			fmt.Fprintf(os.Stdout, "warning: %s\n", warning)
+		}
+		printMetrics(cfg)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s has %d problems", *configPath, len(problems))
+	}
+	fmt.Fprintf(os.Stdout, "%s is valid\n", *configPath)
+	return nil
+}
+
+// printMetrics lists the metrics after includes, templates and defaults are
+// applied
+func printMetrics(cfg *config.Config) {
+	fmt.Fprintf(os.Stdout, "%d metrics:\n", len(cfg.Metrics))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, m := range cfg.Metrics {
+		period := m.Period
+		if period == "" {
+			period = "default"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", m.MetricName, m.Granularity, m.MetricType, period)
+	}
+	_ = tw.Flush()
+}
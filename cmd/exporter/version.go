@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Set at build time with -ldflags "-X main.version=..."
+var (
+	version   = "dev"
+	revision  = "unknown"
+	buildDate = "unknown"
+)
+
+// printVersion prints the build information
+func printVersion() {
+	fmt.Printf("aws-cost-exporter, version %s (revision: %s)\n", version, revision)
+	fmt.Printf("  build date: %s\n", buildDate)
+	fmt.Printf("  go version: %s\n", runtime.Version())
+	fmt.Printf("  platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
+}
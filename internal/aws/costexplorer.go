@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -33,12 +34,30 @@ type CostQuery struct {
 type CostResult struct {
 	Groups []CostGroup
 	Total  float64
+	// Unit is the currency of Total
+	Unit string
+	// Estimated is true when any part of the period is not finalized yet
+	Estimated bool
 }
 
+// CostGroup is the cost of one group over the whole period. For HOURLY
+// queries there is one CostGroup per group and hour, with Start set to the
+// start of the hour; ungrouped HOURLY queries yield one keyless group per hour.
 type CostGroup struct {
-	Keys   []string
-	Amount float64
-	Unit   string
+	Keys      []string
+	Amount    float64
+	Unit      string
+	Start     time.Time
+	Estimated bool
+}
+
+// dateFormat returns the TimePeriod layout expected by Cost Explorer for a
+// granularity: HOURLY requires full timestamps.
+func dateFormat(granularity string) string {
+	if granularity == string(types.GranularityHourly) {
+		return "2006-01-02T15:04:05Z"
+	}
+	return "2006-01-02"
 }
 
 func buildFilter(recordTypes []string, tagFilters []config.TagFilter) *types.Expression {
@@ -94,19 +113,30 @@ func NewCostExplorerClient(cfg *config.Config, accountId string, assumedRoleName
 	}, nil
 }
 
-func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, query *CostQuery) (*CostResult, error) {
-	input := &costexplorer.GetCostAndUsageInput{
+// Input returns the Cost Explorer request of the query.
+func (q *CostQuery) Input() *costexplorer.GetCostAndUsageInput {
+	layout := dateFormat(q.Granularity)
+	return &costexplorer.GetCostAndUsageInput{
 		TimePeriod: &types.DateInterval{
-			Start: aws.String(query.StartDate.Format("2006-01-02")),
-			End:   aws.String(query.EndDate.Format("2006-01-02")),
+			Start: aws.String(q.StartDate.Format(layout)),
+			End:   aws.String(q.EndDate.Format(layout)),
 		},
-		Granularity: types.Granularity(query.Granularity),
-		Metrics:     []string{query.MetricType},
-		GroupBy:     query.GroupBy,
-		Filter:      buildFilter(query.RecordTypes, query.TagFilters),
+		Granularity: types.Granularity(q.Granularity),
+		Metrics:     []string{q.MetricType},
+		GroupBy:     q.GroupBy,
+		Filter:      buildFilter(q.RecordTypes, q.TagFilters),
 	}
+}
+
+func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, query *CostQuery) (*CostResult, error) {
+	layout := dateFormat(query.Granularity)
+	hourly := query.Granularity == string(types.GranularityHourly)
+	input := query.Input()
 
 	var result CostResult
+	// Periods spanning several buckets return the same group once per
+	// bucket, sum them so each group reports the whole period.
+	groupIndex := make(map[string]int)
 
 	for {
 		page, err := c.client.GetCostAndUsage(ctx, input)
@@ -115,6 +145,16 @@ func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, query *CostQue
 		}
 
 		for _, resultByTime := range page.ResultsByTime {
+			var start time.Time
+			if hourly && resultByTime.TimePeriod != nil && resultByTime.TimePeriod.Start != nil {
+				start, err = time.Parse(layout, *resultByTime.TimePeriod.Start)
+				if err != nil {
+					return nil, fmt.Errorf("parsing period start %q: %w", *resultByTime.TimePeriod.Start, err)
+				}
+			}
+
+			result.Estimated = result.Estimated || resultByTime.Estimated
+
 			// Handle grouped results
 			for _, group := range resultByTime.Groups {
 				metric, ok := group.Metrics[query.MetricType]
@@ -129,10 +169,19 @@ func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, query *CostQue
 				if metric.Unit != nil {
 					unit = *metric.Unit
 				}
+				key := start.String() + "\x00" + strings.Join(group.Keys, "\x00")
+				if i, ok := groupIndex[key]; ok {
+					result.Groups[i].Amount += amount
+					result.Groups[i].Estimated = result.Groups[i].Estimated || resultByTime.Estimated
+					continue
+				}
+				groupIndex[key] = len(result.Groups)
 				result.Groups = append(result.Groups, CostGroup{
-					Keys:   group.Keys,
-					Amount: amount,
-					Unit:   unit,
+					Keys:      group.Keys,
+					Amount:    amount,
+					Unit:      unit,
+					Start:     start,
+					Estimated: resultByTime.Estimated,
 				})
 			}
 
@@ -144,6 +193,21 @@ func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, query *CostQue
 						return nil, fmt.Errorf("parsing total amount %q: %w", *metric.Amount, err)
 					}
 					result.Total += amount
+					if metric.Unit != nil {
+						result.Unit = *metric.Unit
+					}
+					if hourly {
+						unit := ""
+						if metric.Unit != nil {
+							unit = *metric.Unit
+						}
+						result.Groups = append(result.Groups, CostGroup{
+							Amount:    amount,
+							Unit:      unit,
+							Start:     start,
+							Estimated: resultByTime.Estimated,
+						})
+					}
 				}
 			}
 		}
@@ -0,0 +1,175 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// costExplorerRequest is the part of a GetCostAndUsage request checked by the
+// tests
+type costExplorerRequest struct {
+	TimePeriod struct {
+		Start string
+		End   string
+	}
+	Granularity   string
+	NextPageToken *string
+}
+
+// newTestClient returns a client of a Cost Explorer stand-in answering the
+// GetCostAndUsage requests with pages, in order. Requests are sent to the
+// returned channel.
+func newTestClient(t *testing.T, pages ...string) (*CostExplorerClient, <-chan costExplorerRequest) {
+	t.Helper()
+	requests := make(chan costExplorerRequest, len(pages))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "AWSInsightsIndexService.GetCostAndUsage" {
+			t.Errorf("X-Amz-Target = %s, want GetCostAndUsage", target)
+		}
+		var req costExplorerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if len(requests) == cap(requests) {
+			t.Errorf("unexpected request %+v", req)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		page := pages[len(requests)]
+		requests <- req
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte(page))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := costexplorer.New(costexplorer.Options{
+		BaseEndpoint:     aws.String(srv.URL),
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+	})
+	return &CostExplorerClient{client: client}, requests
+}
+
+func hourlyQuery(groupBy ...types.GroupDefinition) *CostQuery {
+	return &CostQuery{
+		StartDate:   time.Date(2025, time.March, 1, 8, 0, 0, 0, time.UTC),
+		EndDate:     time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC),
+		Granularity: "HOURLY",
+		MetricType:  "UnblendedCost",
+		GroupBy:     groupBy,
+	}
+}
+
+func hour(h int) time.Time {
+	return time.Date(2025, time.March, 1, h, 0, 0, 0, time.UTC)
+}
+
+func TestGetCostAndUsageHourlyUngrouped(t *testing.T) {
+	client, requests := newTestClient(t, `{
+		"ResultsByTime": [
+			{"TimePeriod": {"Start": "2025-03-01T08:00:00Z", "End": "2025-03-01T09:00:00Z"},
+			 "Total": {"UnblendedCost": {"Amount": "1.25", "Unit": "USD"}}, "Groups": [], "Estimated": false},
+			{"TimePeriod": {"Start": "2025-03-01T09:00:00Z", "End": "2025-03-01T10:00:00Z"},
+			 "Total": {"UnblendedCost": {"Amount": "2.5", "Unit": "USD"}}, "Groups": [], "Estimated": true}
+		]
+	}`)
+
+	result, err := client.GetCostAndUsage(context.Background(), hourlyQuery())
+	if err != nil {
+		t.Fatalf("GetCostAndUsage() error = %v", err)
+	}
+
+	// HOURLY periods are sent as timestamps
+	req := <-requests
+	if req.TimePeriod.Start != "2025-03-01T08:00:00Z" || req.TimePeriod.End != "2025-03-01T10:00:00Z" {
+		t.Errorf("request period = %+v, want 2025-03-01T08:00:00Z to 2025-03-01T10:00:00Z", req.TimePeriod)
+	}
+
+	want := []CostGroup{
+		{Amount: 1.25, Unit: "USD", Start: hour(8)},
+		{Amount: 2.5, Unit: "USD", Start: hour(9), Estimated: true},
+	}
+	assertGroups(t, result.Groups, want)
+	if result.Total != 3.75 || result.Unit != "USD" || !result.Estimated {
+		t.Errorf("total = %v %s estimated %v, want 3.75 USD estimated", result.Total, result.Unit, result.Estimated)
+	}
+}
+
+func TestGetCostAndUsageHourlyPages(t *testing.T) {
+	// The groups of an hour can be split across pages
+	client, requests := newTestClient(t, `{
+		"ResultsByTime": [
+			{"TimePeriod": {"Start": "2025-03-01T08:00:00Z", "End": "2025-03-01T09:00:00Z"}, "Groups": [
+				{"Keys": ["AWS Lambda"], "Metrics": {"UnblendedCost": {"Amount": "1", "Unit": "USD"}}},
+				{"Keys": ["Amazon S3"], "Metrics": {"UnblendedCost": {"Amount": "2", "Unit": "USD"}}}
+			]}
+		],
+		"NextPageToken": "page-2"
+	}`, `{
+		"ResultsByTime": [
+			{"TimePeriod": {"Start": "2025-03-01T08:00:00Z", "End": "2025-03-01T09:00:00Z"}, "Groups": [
+				{"Keys": ["AWS Lambda"], "Metrics": {"UnblendedCost": {"Amount": "0.5", "Unit": "USD"}}}
+			], "Estimated": true},
+			{"TimePeriod": {"Start": "2025-03-01T09:00:00Z", "End": "2025-03-01T10:00:00Z"}, "Groups": [
+				{"Keys": ["AWS Lambda"], "Metrics": {"UnblendedCost": {"Amount": "3", "Unit": "USD"}}}
+			]}
+		]
+	}`)
+
+	query := hourlyQuery(types.GroupDefinition{Type: types.GroupDefinitionTypeDimension, Key: aws.String("SERVICE")})
+	result, err := client.GetCostAndUsage(context.Background(), query)
+	if err != nil {
+		t.Fatalf("GetCostAndUsage() error = %v", err)
+	}
+
+	if first := <-requests; first.NextPageToken != nil {
+		t.Errorf("first request page token = %q, want none", *first.NextPageToken)
+	}
+	if second := <-requests; second.NextPageToken == nil || *second.NextPageToken != "page-2" {
+		t.Errorf("second request page token = %v, want page-2", second.NextPageToken)
+	}
+
+	want := []CostGroup{
+		{Keys: []string{"AWS Lambda"}, Amount: 1.5, Unit: "USD", Start: hour(8), Estimated: true},
+		{Keys: []string{"Amazon S3"}, Amount: 2, Unit: "USD", Start: hour(8)},
+		{Keys: []string{"AWS Lambda"}, Amount: 3, Unit: "USD", Start: hour(9)},
+	}
+	assertGroups(t, result.Groups, want)
+}
+
+func TestGetCostAndUsageHourlyInvalidStart(t *testing.T) {
+	client, _ := newTestClient(t, `{
+		"ResultsByTime": [
+			{"TimePeriod": {"Start": "2025-03-01", "End": "2025-03-02"},
+			 "Total": {"UnblendedCost": {"Amount": "1", "Unit": "USD"}}}
+		]
+	}`)
+
+	if _, err := client.GetCostAndUsage(context.Background(), hourlyQuery()); err == nil {
+		t.Error("GetCostAndUsage() succeeded, want an error parsing the period start")
+	}
+}
+
+func assertGroups(t *testing.T, got, want []CostGroup) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("groups = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !slices.Equal(g.Keys, w.Keys) || g.Amount != w.Amount || g.Unit != w.Unit || !g.Start.Equal(w.Start) || g.Estimated != w.Estimated {
+			t.Errorf("group %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
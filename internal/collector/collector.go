@@ -2,9 +2,13 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
@@ -12,27 +16,52 @@ import (
 
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/aws"
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/currency"
 	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
 )
 
+// ErrUnknownScope is returned when refreshing an account or metric not in
+// the config.
+var ErrUnknownScope = errors.New("unknown refresh scope")
+
 type CostCollector struct {
 	mu         sync.RWMutex
+	refreshMu  sync.Mutex
 	metrics    map[string]*prometheus.GaugeVec
+	periods    map[periodKey]timeutil.Period
+	entries    []CostEntry
+	currencies map[string]string // account id -> latest currency
 	awsClients map[string]*aws.CostExplorerClient
 	config     *config.Config
+	clock      timeutil.Clock
+	converter  *currency.Converter
 	logger     *slog.Logger
 
+	// Whether the latest data of each metric is estimated
+	estimated *prometheus.GaugeVec
+
 	// Internal metrics
 	scrapeErrors   prometheus.Counter
 	scrapeDuration prometheus.Histogram
 }
 
-func New(cfg *config.Config, logger *slog.Logger) (*CostCollector, error) {
+func New(cfg *config.Config, clock timeutil.Clock, logger *slog.Logger) (*CostCollector, error) {
+	if clock == nil {
+		clock = timeutil.SystemClock{}
+	}
+
 	c := &CostCollector{
 		metrics:    make(map[string]*prometheus.GaugeVec),
+		periods:    make(map[periodKey]timeutil.Period),
+		currencies: make(map[string]string),
 		awsClients: make(map[string]*aws.CostExplorerClient),
 		config:     cfg,
+		clock:      clock,
 		logger:     logger,
+		estimated: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "aws_cost_estimated",
+			Help: "Whether the latest cost data of a metric is estimated (1) or final (0)",
+		}, []string{"account_id", "metric_name"}),
 		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "aws_cost_exporter_scrape_errors_total",
 			Help: "Total number of scrape errors",
@@ -44,6 +73,12 @@ func New(cfg *config.Config, logger *slog.Logger) (*CostCollector, error) {
 		}),
 	}
 
+	converter, err := currency.New(cfg.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("creating currency converter: %w", err)
+	}
+	c.converter = converter
+
 	// Init metrics from config
 	for _, metricCfg := range cfg.Metrics {
 		labels := buildLabelNames(cfg, &metricCfg)
@@ -77,6 +112,7 @@ func (c *CostCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, metric := range c.metrics {
 		metric.Describe(ch)
 	}
+	c.estimated.Describe(ch)
 	c.scrapeErrors.Describe(ch)
 	c.scrapeDuration.Describe(ch)
 }
@@ -89,31 +125,123 @@ func (c *CostCollector) Collect(ch chan<- prometheus.Metric) {
 	for _, metric := range c.metrics {
 		metric.Collect(ch)
 	}
+	c.estimated.Collect(ch)
 	c.scrapeErrors.Collect(ch)
 	c.scrapeDuration.Collect(ch)
 }
 
+// periodKey identifies the period of a metric for an account, which may
+// differ between accounts overriding the data delay.
+type periodKey struct {
+	accountID string
+	metric    string
+}
+
+// Period returns the period of the latest data of a metric for an account.
+func (c *CostCollector) Period(accountID, metricName string) (timeutil.Period, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	period, ok := c.periods[periodKey{accountID: accountID, metric: metricName}]
+	return period, ok
+}
+
 // accountResults holds the fetched results for one account
 type accountResults struct {
 	account config.AWSAccount
-	results map[string]*aws.CostResult // metric name -> result
+	// metrics selected for the account, with its overrides
+	metrics   []config.MetricConfig
+	results   map[string]*aws.CostResult // metric name -> result
+	periods   map[string]timeutil.Period // metric name -> query period
+	fetchedAt time.Time
 }
 
 // Get data from all accounts (called by the poller)
 func (c *CostCollector) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, c.config.TargetAWSAccounts, c.config.Metrics, true)
+}
+
+// RefreshScope gets the data of the given accounts and metrics only, all of
+// them when empty. Data of the other accounts and metrics is kept.
+func (c *CostCollector) RefreshScope(ctx context.Context, accountIDs, metricNames []string) error {
+	accounts, metrics, err := c.resolveScope(accountIDs, metricNames)
+	if err != nil {
+		return err
+	}
+
+	full := len(accountIDs) == 0 && len(metricNames) == 0
+	return c.refresh(ctx, accounts, metrics, full)
+}
+
+// ValidateScope checks that the accounts and metrics of a scope are in the
+// config.
+func (c *CostCollector) ValidateScope(accountIDs, metricNames []string) error {
+	_, _, err := c.resolveScope(accountIDs, metricNames)
+	return err
+}
+
+func (c *CostCollector) resolveScope(accountIDs, metricNames []string) ([]config.AWSAccount, []config.MetricConfig, error) {
+	accounts := c.config.TargetAWSAccounts
+	if len(accountIDs) > 0 {
+		accounts = nil
+		for _, id := range accountIDs {
+			i := slices.IndexFunc(c.config.TargetAWSAccounts, func(a config.AWSAccount) bool { return a.AccountId == id })
+			if i < 0 {
+				return nil, nil, fmt.Errorf("%w: account %s", ErrUnknownScope, id)
+			}
+			accounts = append(accounts, c.config.TargetAWSAccounts[i])
+		}
+	}
+
+	metrics := c.config.Metrics
+	if len(metricNames) > 0 {
+		metrics = nil
+		for _, name := range metricNames {
+			i := slices.IndexFunc(c.config.Metrics, func(m config.MetricConfig) bool { return m.MetricName == name })
+			if i < 0 {
+				return nil, nil, fmt.Errorf("%w: metric %s", ErrUnknownScope, name)
+			}
+			metrics = append(metrics, c.config.Metrics[i])
+		}
+	}
+
+	return accounts, metrics, nil
+}
+
+// refresh fetches the metrics of the accounts. A full refresh resets all
+// metrics, dropping the data of failed accounts, a partial refresh only
+// replaces the data fetched.
+func (c *CostCollector) refresh(ctx context.Context, accounts []config.AWSAccount, metrics []config.MetricConfig, full bool) error {
+	// Avoid concurrent refreshes querying the same data
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
 	timer := prometheus.NewTimer(c.scrapeDuration)
 	defer timer.ObserveDuration()
 
+	if c.converter != nil {
+		if err := c.converter.Reload(); err != nil {
+			c.logger.Warn("failed to reload currency rates, keeping previous rates", "error", err)
+		}
+	}
+
+	// Build queries once so all accounts share the same periods
+	queries := make(map[string]*aws.CostQuery, len(metrics))
+	for _, metricCfg := range metrics {
+		queries[metricCfg.MetricName] = BuildQuery(c.clock, &metricCfg)
+	}
+
 	// Fetch all accounts in parallel (without holding the lock)
 	var wg sync.WaitGroup
-	resultsCh := make(chan accountResults, len(c.config.TargetAWSAccounts))
-	errCh := make(chan error, len(c.config.TargetAWSAccounts))
+	resultsCh := make(chan accountResults, len(accounts))
+	errCh := make(chan error, len(accounts))
 
-	for _, account := range c.config.TargetAWSAccounts {
+	for _, account := range accounts {
 		wg.Add(1)
 		go func(acc config.AWSAccount) {
 			defer wg.Done()
-			results, err := c.fetchAccountCosts(ctx, acc)
+			accountMetrics := acc.SelectMetrics(metrics)
+			results, periods, err := c.fetchAccountCosts(ctx, acc, accountMetrics, queries)
 			if err != nil {
 				c.logger.Error("failed to fetch costs",
 					"account", acc.AccountId,
@@ -122,7 +250,13 @@ func (c *CostCollector) Refresh(ctx context.Context) error {
 				errCh <- err
 				return
 			}
-			resultsCh <- accountResults{account: acc, results: results}
+			resultsCh <- accountResults{
+				account:   acc,
+				metrics:   accountMetrics,
+				results:   results,
+				periods:   periods,
+				fetchedAt: time.Now(),
+			}
 		}(account)
 	}
 
@@ -136,15 +270,37 @@ func (c *CostCollector) Refresh(ctx context.Context) error {
 		allResults = append(allResults, r)
 	}
 
+	if c.converter == nil {
+		c.warnMixedCurrencies(allResults)
+	}
+
 	// Now atomically reset and update all metrics
 	c.mu.Lock()
-	for _, metric := range c.metrics {
-		metric.Reset()
+	if full {
+		for _, metric := range c.metrics {
+			metric.Reset()
+		}
+		c.estimated.Reset()
+		c.entries = nil
+		clear(c.periods)
+	} else {
+		c.deleteResults(allResults, metrics)
 	}
 	for _, ar := range allResults {
-		for _, metricCfg := range c.config.Metrics {
+		for name, period := range ar.periods {
+			c.periods[periodKey{accountID: ar.account.AccountId, metric: name}] = period
+		}
+		if currency := resultsCurrency(ar.results); currency != "" {
+			c.currencies[ar.account.AccountId] = currency
+		}
+		for _, metricCfg := range ar.metrics {
 			if result, ok := ar.results[metricCfg.MetricName]; ok {
-				c.updateMetrics(ar.account, &metricCfg, result)
+				c.updateMetrics(ar, &metricCfg, result)
+				estimated := 0.0
+				if result.Estimated {
+					estimated = 1
+				}
+				c.estimated.WithLabelValues(ar.account.AccountId, metricCfg.MetricName).Set(estimated)
 			}
 		}
 	}
@@ -163,31 +319,93 @@ func (c *CostCollector) Refresh(ctx context.Context) error {
 	return nil
 }
 
-func (c *CostCollector) fetchAccountCosts(ctx context.Context, account config.AWSAccount) (map[string]*aws.CostResult, error) {
+// deleteResults removes the series and entries of the fetched accounts and
+// metrics before a partial update. Must be called with the lock held.
+func (c *CostCollector) deleteResults(allResults []accountResults, metrics []config.MetricConfig) {
+	scope := make(map[string]map[string]bool)
+	for _, ar := range allResults {
+		scope[ar.account.AccountId] = make(map[string]bool)
+		for _, metricCfg := range metrics {
+			scope[ar.account.AccountId][metricCfg.MetricName] = true
+			c.metrics[metricCfg.MetricName].DeletePartialMatch(prometheus.Labels{"account_id": ar.account.AccountId})
+			c.estimated.DeleteLabelValues(ar.account.AccountId, metricCfg.MetricName)
+		}
+	}
+	c.entries = slices.DeleteFunc(c.entries, func(e CostEntry) bool {
+		return scope[e.AccountID][e.Metric]
+	})
+}
+
+// warnMixedCurrencies logs metrics whose results are in several currencies
+func (c *CostCollector) warnMixedCurrencies(allResults []accountResults) {
+	for _, metricCfg := range c.config.Metrics {
+		units := make(map[string]bool)
+		for _, ar := range allResults {
+			result, ok := ar.results[metricCfg.MetricName]
+			if !ok {
+				continue
+			}
+			if result.Unit != "" {
+				units[result.Unit] = true
+			}
+			for _, group := range result.Groups {
+				if group.Unit != "" {
+					units[group.Unit] = true
+				}
+			}
+		}
+		if len(units) > 1 {
+			c.logger.Warn("metric aggregates mixed currencies, configure currency conversion",
+				"metric", metricCfg.MetricName,
+				"currencies", slices.Sorted(maps.Keys(units)))
+		}
+	}
+}
+
+// fetchAccountCosts queries the metrics selected for an account. Accounts
+// overriding query settings get their own queries, others share the queries
+// built for all accounts.
+func (c *CostCollector) fetchAccountCosts(ctx context.Context, account config.AWSAccount, metrics []config.MetricConfig, queries map[string]*aws.CostQuery) (map[string]*aws.CostResult, map[string]timeutil.Period, error) {
 	client, ok := c.awsClients[account.AccountId]
 	if !ok {
-		return nil, fmt.Errorf("no client found for account %s", account.AccountId)
+		return nil, nil, fmt.Errorf("no client found for account %s", account.AccountId)
 	}
 
 	results := make(map[string]*aws.CostResult)
-	for _, metricCfg := range c.config.Metrics {
-		query := buildQuery(&metricCfg)
+	periods := make(map[string]timeutil.Period)
+	for _, metricCfg := range metrics {
+		query := queries[metricCfg.MetricName]
+		if account.HasQueryOverrides() {
+			query = BuildQuery(c.clock, &metricCfg)
+		}
+		periods[metricCfg.MetricName] = timeutil.Period{Start: query.StartDate, End: query.EndDate}
+		if !query.EndDate.After(query.StartDate) {
+			c.logger.Debug("skipping metric with empty period",
+				"account", account.AccountId,
+				"metric", metricCfg.MetricName,
+				"policy", metricCfg.EmptyPeriodPolicy)
+			if metricCfg.EmptyPeriodPolicy == "zero" {
+				results[metricCfg.MetricName] = &aws.CostResult{}
+			}
+			continue
+		}
 		result, err := client.GetCostAndUsage(ctx, query)
 		if err != nil {
-			return nil, fmt.Errorf("metric %s: %w", metricCfg.MetricName, err)
+			return nil, nil, fmt.Errorf("metric %s: %w", metricCfg.MetricName, err)
 		}
 		results[metricCfg.MetricName] = result
 	}
 
-	return results, nil
+	return results, periods, nil
 }
 
-func buildQuery(metricCfg *config.MetricConfig) *aws.CostQuery {
-	var period timeutil.Period
-	if metricCfg.Granularity == "DAILY" {
-		period = timeutil.DailyPeriod(metricCfg.DataDelayDays)
-	} else {
-		period = timeutil.MonthlyPeriod(metricCfg.DataDelayDays)
+// BuildQuery returns the Cost Explorer query of a metric for its current
+// period.
+func BuildQuery(clock timeutil.Clock, metricCfg *config.MetricConfig) *aws.CostQuery {
+	period := buildPeriod(clock, metricCfg)
+	// Validation restricts the previous_month policy to month to date
+	if period.Empty() && metricCfg.EmptyPeriodPolicy == "previous_month" && metricCfg.MonthToDate() {
+		period = timeutil.PreviousMonthPeriod(clock, metricCfg.DataDelayDays)
 	}
 
 	var groupBy []types.GroupDefinition
@@ -211,35 +429,186 @@ func buildQuery(metricCfg *config.MetricConfig) *aws.CostQuery {
 	}
 }
 
-func (c *CostCollector) updateMetrics(account config.AWSAccount, metricCfg *config.MetricConfig, result *aws.CostResult) {
-	gauge := c.metrics[metricCfg.MetricName]
+// buildPeriod returns the query window of a metric. Without an explicit
+// period, HOURLY metrics query the last 24 hours, DAILY metrics yesterday and
+// MONTHLY metrics the month to date.
+func buildPeriod(clock timeutil.Clock, metricCfg *config.MetricConfig) timeutil.Period {
+	delay := metricCfg.DataDelayDays
+
+	switch metricCfg.Period {
+	case "daily":
+		return timeutil.DailyPeriod(clock, delay)
+	case "month_to_date":
+		return timeutil.MonthlyPeriod(clock, delay)
+	case "previous_month":
+		return timeutil.PreviousMonthPeriod(clock, delay)
+	case "last_7_days":
+		return timeutil.RollingPeriod(clock, 7, delay)
+	case "last_30_days":
+		return timeutil.RollingPeriod(clock, 30, delay)
+	case "week_to_date":
+		return timeutil.WeekToDatePeriod(clock, delay)
+	case "quarter_to_date":
+		return timeutil.QuarterToDatePeriod(clock, delay)
+	case "year_to_date":
+		return timeutil.YearToDatePeriod(clock, delay)
+	case "fiscal_year_to_date":
+		startMonth := time.January
+		if metricCfg.FiscalYearStartMonth > 0 {
+			startMonth = time.Month(metricCfg.FiscalYearStartMonth)
+		}
+		return timeutil.FiscalYearToDatePeriod(clock, startMonth, delay)
+	}
 
-	if metricCfg.GroupBy == nil || !metricCfg.GroupBy.Enabled {
-		labels := buildLabelValues(account, metricCfg, nil)
-		gauge.WithLabelValues(labels...).Set(result.Total)
+	switch metricCfg.Granularity {
+	case "HOURLY":
+		return timeutil.HourlyPeriod(clock, delay)
+	case "DAILY":
+		return timeutil.DailyPeriod(clock, delay)
+	}
+	return timeutil.MonthlyPeriod(clock, delay)
+}
+
+// convert returns amount in the configured target currency together with its
+// currency. Without conversion, or when no rate is known, the amount is
+// returned unchanged in its original currency.
+func (c *CostCollector) convert(account config.AWSAccount, amount float64, unit string) (float64, string) {
+	if c.converter == nil {
+		return amount, unit
+	}
+	converted, err := c.converter.Convert(amount, unit)
+	if err != nil {
+		c.logger.Warn("failed to convert cost",
+			"account", account.AccountId,
+			"error", err)
+		return amount, unit
+	}
+	return converted, c.converter.Target()
+}
+
+// resultsCurrency returns the currency of an account's results, empty when
+// no result has a cost.
+func resultsCurrency(results map[string]*aws.CostResult) string {
+	for _, result := range results {
+		if result.Unit != "" {
+			return result.Unit
+		}
+		for _, group := range result.Groups {
+			if group.Unit != "" {
+				return group.Unit
+			}
+		}
+	}
+	return ""
+}
+
+// setZeroCost sets an explicit zero cost for a metric whose period is empty,
+// in the configured billing currency of the account, else the latest currency
+// it reported, else USD. Grouped metrics get a single series keyed by the
+// merged minor cost tag value, or by empty keys without merging. Must be
+// called with the lock held.
+func (c *CostCollector) setZeroCost(account config.AWSAccount, metricCfg *config.MetricConfig, entry CostEntry) {
+	currency := account.Currency
+	if currency == "" {
+		currency = c.currencies[account.AccountId]
+	}
+	if currency == "" {
+		currency = "USD"
+	}
+	entry.Amount, entry.Unit = c.convert(account, 0, currency)
+
+	if metricCfg.GroupBy != nil && metricCfg.GroupBy.Enabled {
+		entry.Keys = make([]string, len(metricCfg.GroupBy.Groups))
+		if merge := metricCfg.GroupBy.MergeMinorCost; merge != nil && merge.Enabled {
+			for i := range entry.Keys {
+				entry.Keys[i] = merge.TagValue
+			}
+		}
+	}
+
+	labels := buildLabelValues(account, metricCfg, entry.Unit, entry.Keys)
+	if metricCfg.Granularity == "HOURLY" {
+		labels = append(labels, formatHour(entry.Period.Start))
+	}
+	c.setCost(metricCfg, entry, labels)
+}
+
+// mergeKey identifies a merged minor cost series
+type mergeKey struct {
+	start    time.Time
+	currency string
+}
+
+func (c *CostCollector) updateMetrics(ar accountResults, metricCfg *config.MetricConfig, result *aws.CostResult) {
+	account := ar.account
+	hourly := metricCfg.Granularity == "HOURLY"
+	entry := CostEntry{
+		AccountID: account.AccountId,
+		Metric:    metricCfg.MetricName,
+		Period:    ar.periods[metricCfg.MetricName],
+		FetchedAt: ar.fetchedAt,
+	}
+
+	if entry.Period.Empty() {
+		c.setZeroCost(account, metricCfg, entry)
+		return
+	}
+
+	if !hourly && (metricCfg.GroupBy == nil || !metricCfg.GroupBy.Enabled) {
+		entry.Amount, entry.Unit = c.convert(account, result.Total, result.Unit)
+		entry.Estimated = result.Estimated
+		labels := buildLabelValues(account, metricCfg, entry.Unit, nil)
+		c.setCost(metricCfg, entry, labels)
 		return
 	}
 
-	var mergedMinorCost float64
-	mergeEnabled := metricCfg.GroupBy.MergeMinorCost != nil &&
-		metricCfg.GroupBy.MergeMinorCost.Enabled
+	// Minor costs are merged per hour for HOURLY metrics
+	mergedMinorCost := make(map[mergeKey]float64)
+	mergedEstimated := make(map[mergeKey]bool)
+	mergeEnabled := metricCfg.GroupBy != nil && metricCfg.GroupBy.Enabled &&
+		metricCfg.GroupBy.MergeMinorCost != nil && metricCfg.GroupBy.MergeMinorCost.Enabled
 
 	for _, group := range result.Groups {
-		if mergeEnabled && group.Amount < metricCfg.GroupBy.MergeMinorCost.Threshold {
-			mergedMinorCost += group.Amount
+		amount, currency := c.convert(account, group.Amount, group.Unit)
+		if mergeEnabled && amount < metricCfg.GroupBy.MergeMinorCost.Threshold {
+			key := mergeKey{start: group.Start, currency: currency}
+			mergedMinorCost[key] += amount
+			mergedEstimated[key] = mergedEstimated[key] || group.Estimated
 			continue
 		}
 
-		labels := buildLabelValues(account, metricCfg, group.Keys)
-		gauge.WithLabelValues(labels...).Set(group.Amount)
+		groupEntry := entry
+		groupEntry.Keys = group.Keys
+		groupEntry.Amount = amount
+		groupEntry.Unit = currency
+		groupEntry.Estimated = group.Estimated
+		labels := buildLabelValues(account, metricCfg, currency, group.Keys)
+		if hourly {
+			groupEntry.Period = timeutil.Period{Start: group.Start, End: group.Start.Add(time.Hour)}
+			labels = append(labels, formatHour(group.Start))
+		}
+		c.setCost(metricCfg, groupEntry, labels)
 	}
 
-	if mergedMinorCost > 0 {
+	for key, amount := range mergedMinorCost {
+		if amount <= 0 {
+			continue
+		}
 		mergedKeys := make([]string, len(metricCfg.GroupBy.Groups))
 		for i := range mergedKeys {
 			mergedKeys[i] = metricCfg.GroupBy.MergeMinorCost.TagValue
 		}
-		labels := buildLabelValues(account, metricCfg, mergedKeys)
-		gauge.WithLabelValues(labels...).Set(mergedMinorCost)
+
+		mergedEntry := entry
+		mergedEntry.Keys = mergedKeys
+		mergedEntry.Amount = amount
+		mergedEntry.Unit = key.currency
+		mergedEntry.Estimated = mergedEstimated[key]
+		labels := buildLabelValues(account, metricCfg, key.currency, mergedKeys)
+		if hourly {
+			mergedEntry.Period = timeutil.Period{Start: key.start, End: key.start.Add(time.Hour)}
+			labels = append(labels, formatHour(key.start))
+		}
+		c.setCost(metricCfg, mergedEntry, labels)
 	}
 }
@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// firstOfMonth makes month to date periods empty
+var firstOfMonth = timeutil.FixedClock(time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC))
+
+func newTestCollector(t *testing.T, account config.AWSAccount, metrics ...config.MetricConfig) *CostCollector {
+	t.Helper()
+	cfg := &config.Config{
+		TargetAWSAccounts: []config.AWSAccount{account},
+		Metrics:           metrics,
+	}
+	c, err := New(cfg, firstOfMonth, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func monthToDateMetric(policy string, groupBy *config.GroupByConfig) config.MetricConfig {
+	return config.MetricConfig{
+		MetricName:        "cost",
+		Granularity:       "MONTHLY",
+		MetricType:        "UnblendedCost",
+		Period:            "month_to_date",
+		EmptyPeriodPolicy: policy,
+		GroupBy:           groupBy,
+	}
+}
+
+func TestBuildQueryEmptyPeriodPolicy(t *testing.T) {
+	tests := []struct {
+		policy    string
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"skip", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"zero", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"previous_month", time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			metricCfg := monthToDateMetric(tt.policy, nil)
+			query := BuildQuery(firstOfMonth, &metricCfg)
+			if !query.StartDate.Equal(tt.wantStart) || !query.EndDate.Equal(tt.wantEnd) {
+				t.Errorf("BuildQuery() period = %s - %s, want %s - %s",
+					query.StartDate, query.EndDate, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestBuildQueryPeriods(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	// Each clock makes the period empty when it can be
+	tests := []struct {
+		name        string
+		now         time.Time
+		granularity string
+		period      string
+		wantStart   time.Time
+		wantEnd     time.Time
+	}{
+		{"default HOURLY", day(time.March, 1).Add(10 * time.Hour), "HOURLY", "", day(time.February, 28).Add(10 * time.Hour), day(time.March, 1).Add(10 * time.Hour)},
+		{"default DAILY", day(time.March, 1), "DAILY", "", day(time.February, 28), day(time.March, 1)},
+		{"default MONTHLY", day(time.March, 1), "MONTHLY", "", day(time.February, 1), day(time.March, 1)},
+		{"daily", day(time.March, 1), "DAILY", "daily", day(time.February, 28), day(time.March, 1)},
+		{"month_to_date", day(time.March, 1), "DAILY", "month_to_date", day(time.February, 1), day(time.March, 1)},
+		{"previous_month", day(time.March, 1), "MONTHLY", "previous_month", day(time.February, 1), day(time.March, 1)},
+		{"last_7_days", day(time.March, 1), "DAILY", "last_7_days", day(time.February, 22), day(time.March, 1)},
+		{"last_30_days", day(time.March, 1), "DAILY", "last_30_days", day(time.January, 30), day(time.March, 1)},
+		{"week_to_date", day(time.March, 3), "DAILY", "week_to_date", day(time.March, 3), day(time.March, 3)},
+		{"quarter_to_date", day(time.April, 1), "MONTHLY", "quarter_to_date", day(time.April, 1), day(time.April, 1)},
+		{"year_to_date", day(time.January, 1), "MONTHLY", "year_to_date", day(time.January, 1), day(time.January, 1)},
+		{"fiscal_year_to_date", day(time.April, 1), "MONTHLY", "fiscal_year_to_date", day(time.April, 1), day(time.April, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The previous_month policy only replaces an empty month to date
+			metricCfg := config.MetricConfig{
+				MetricName:           "cost",
+				Granularity:          tt.granularity,
+				MetricType:           "UnblendedCost",
+				Period:               tt.period,
+				FiscalYearStartMonth: 4,
+				EmptyPeriodPolicy:    "previous_month",
+			}
+			query := BuildQuery(timeutil.FixedClock(tt.now), &metricCfg)
+			if !query.StartDate.Equal(tt.wantStart) || !query.EndDate.Equal(tt.wantEnd) {
+				t.Errorf("BuildQuery() period = %s - %s, want %s - %s",
+					query.StartDate, query.EndDate, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestRefreshEmptyPeriod(t *testing.T) {
+	grouped := &config.GroupByConfig{
+		Enabled: true,
+		Groups:  []config.GroupConfig{{Type: "DIMENSION", Key: "SERVICE", LabelName: "service"}},
+	}
+	merged := &config.GroupByConfig{
+		Enabled:        true,
+		Groups:         grouped.Groups,
+		MergeMinorCost: &config.MergeConfig{Enabled: true, Threshold: 1, TagValue: "other"},
+	}
+
+	tests := []struct {
+		name     string
+		account  config.AWSAccount
+		metric   config.MetricConfig
+		want     bool
+		currency string
+		service  string
+	}{
+		{
+			name:    "skip",
+			account: config.AWSAccount{AccountId: "123456789012"},
+			metric:  monthToDateMetric("skip", nil),
+		},
+		{
+			name:    "default skips",
+			account: config.AWSAccount{AccountId: "123456789012"},
+			metric:  monthToDateMetric("", grouped),
+		},
+		{
+			name:     "zero ungrouped",
+			account:  config.AWSAccount{AccountId: "123456789012"},
+			metric:   monthToDateMetric("zero", nil),
+			want:     true,
+			currency: "USD",
+		},
+		{
+			name:     "zero grouped",
+			account:  config.AWSAccount{AccountId: "123456789012", Currency: "EUR"},
+			metric:   monthToDateMetric("zero", grouped),
+			want:     true,
+			currency: "EUR",
+			service:  "",
+		},
+		{
+			name:     "zero merged",
+			account:  config.AWSAccount{AccountId: "123456789012"},
+			metric:   monthToDateMetric("zero", merged),
+			want:     true,
+			currency: "USD",
+			service:  "other",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector(t, tt.account, tt.metric)
+			// Empty periods are not queried
+			if err := c.Refresh(context.Background()); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			entries := c.Costs()
+			if !tt.want {
+				if len(entries) != 0 {
+					t.Fatalf("Costs() = %v, want none", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("Costs() = %v, want one entry", entries)
+			}
+
+			entry := entries[0]
+			if entry.Amount != 0 || entry.Unit != tt.currency || entry.Labels["currency"] != tt.currency {
+				t.Errorf("entry amount = %v %s, currency label %q, want 0 %s",
+					entry.Amount, entry.Unit, entry.Labels["currency"], tt.currency)
+			}
+			if tt.metric.GroupBy != nil {
+				service, ok := entry.Labels["service"]
+				if !ok || service != tt.service {
+					t.Errorf("service label = %q, want %q", service, tt.service)
+				}
+				if !slices.Equal(entry.Keys, []string{tt.service}) {
+					t.Errorf("keys = %v, want [%s]", entry.Keys, tt.service)
+				}
+			}
+			if !entry.Period.Empty() {
+				t.Errorf("period = %v, want empty", entry.Period)
+			}
+		})
+	}
+}
+
+func TestRefreshPeriodPerAccount(t *testing.T) {
+	delay := 28
+	cfg := &config.Config{
+		TargetAWSAccounts: []config.AWSAccount{
+			{AccountId: "111111111111"},
+			{AccountId: "222222222222", Overrides: &config.AccountOverrides{DataDelayDays: &delay}},
+		},
+		Metrics: []config.MetricConfig{monthToDateMetric("zero", nil)},
+	}
+	c, err := New(cfg, firstOfMonth, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// Both periods are empty and not queried
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	tests := []struct {
+		accountID string
+		wantStart time.Time
+	}{
+		{"111111111111", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"222222222222", time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		period, ok := c.Period(tt.accountID, "cost")
+		if !ok || !period.Start.Equal(tt.wantStart) {
+			t.Errorf("Period(%s) = %v, %v, want start %s", tt.accountID, period, ok, tt.wantStart)
+		}
+		for _, entry := range c.Costs() {
+			if entry.AccountID == tt.accountID && entry.Period != period {
+				t.Errorf("entry period of %s = %v, want %v", tt.accountID, entry.Period, period)
+			}
+		}
+	}
+
+	if _, ok := c.Period("333333333333", "cost"); ok {
+		t.Error("Period() of an unknown account found")
+	}
+}
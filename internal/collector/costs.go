@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"slices"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// CostEntry is one cost series of the latest refresh, as exposed by the
+// gauges.
+type CostEntry struct {
+	AccountID string
+	Metric    string
+	Period    timeutil.Period
+	// Keys are the raw group keys returned by Cost Explorer
+	Keys []string
+	// Labels are the gauge labels, including account labels and aliases
+	Labels    map[string]string
+	Amount    float64
+	Unit      string
+	Estimated bool
+	FetchedAt time.Time
+}
+
+// Costs returns the cost entries of the latest refresh.
+func (c *CostCollector) Costs() []CostEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return slices.Clone(c.entries)
+}
+
+// setCost sets the gauge of a metric and records the matching entry. Must be
+// called with the lock held.
+func (c *CostCollector) setCost(metricCfg *config.MetricConfig, entry CostEntry, labelValues []string) {
+	c.metrics[metricCfg.MetricName].WithLabelValues(labelValues...).Set(entry.Amount)
+
+	names := buildLabelNames(c.config, metricCfg)
+	entry.Labels = make(map[string]string, len(names))
+	for i, name := range names {
+		if i < len(labelValues) {
+			entry.Labels[name] = labelValues[i]
+		}
+	}
+	c.entries = append(c.entries, entry)
+}
@@ -3,6 +3,7 @@ package collector
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
 )
@@ -33,21 +34,22 @@ func buildLabelNames(cfg *config.Config, metricCfg *config.MetricConfig) []strin
 		labels = append(labels, getSortedLabelKeys(cfg.TargetAWSAccounts[0])...)
 	}
 
-	labels = append(labels, "charge_type")
+	labels = append(labels, "charge_type", "currency")
+	labels = append(labels, GroupLabelNames(metricCfg)...)
 
-	if metricCfg.GroupBy != nil && metricCfg.GroupBy.Enabled {
-		for _, group := range metricCfg.GroupBy.Groups {
-			labels = append(labels, group.LabelName)
-			if group.Alias != nil {
-				labels = append(labels, group.Alias.LabelName)
-			}
-		}
+	if metricCfg.Granularity == "HOURLY" {
+		labels = append(labels, "hour")
 	}
 
 	return labels
 }
 
-func buildLabelValues(account config.AWSAccount, metricCfg *config.MetricConfig, keys []string) []string {
+// formatHour returns the hour label value of an HOURLY cost group
+func formatHour(start time.Time) string {
+	return start.UTC().Format(time.RFC3339)
+}
+
+func buildLabelValues(account config.AWSAccount, metricCfg *config.MetricConfig, currency string, keys []string) []string {
 	var values []string
 	values = append(values, account.AccountId)
 
@@ -55,32 +57,56 @@ func buildLabelValues(account config.AWSAccount, metricCfg *config.MetricConfig,
 		values = append(values, account.Labels[key])
 	}
 
-	values = append(values, getChargeType(metricCfg))
+	values = append(values, getChargeType(metricCfg), currency)
+	return append(values, GroupLabelValues(metricCfg, keys)...)
+}
 
-	if metricCfg.GroupBy != nil && metricCfg.GroupBy.Enabled {
-		for i, group := range metricCfg.GroupBy.Groups {
-			keyValue := ""
-			if i < len(keys) {
-				keyValue = keys[i]
-			}
-			// strip the prefix to keep only the tag value.
-			if group.Type == "TAG" {
-				if prefix := group.Key + "$"; strings.HasPrefix(keyValue, prefix) {
-					keyValue = strings.TrimPrefix(keyValue, prefix)
-				}
+// GroupLabelNames returns the label names of the groups of a metric, each
+// followed by the label name of its alias.
+func GroupLabelNames(metricCfg *config.MetricConfig) []string {
+	if metricCfg.GroupBy == nil || !metricCfg.GroupBy.Enabled {
+		return nil
+	}
+
+	var labels []string
+	for _, group := range metricCfg.GroupBy.Groups {
+		labels = append(labels, group.LabelName)
+		if group.Alias != nil {
+			labels = append(labels, group.Alias.LabelName)
+		}
+	}
+	return labels
+}
+
+// GroupLabelValues returns the label values of the group keys returned by
+// Cost Explorer, matching GroupLabelNames. Missing keys are empty.
+func GroupLabelValues(metricCfg *config.MetricConfig, keys []string) []string {
+	if metricCfg.GroupBy == nil || !metricCfg.GroupBy.Enabled {
+		return nil
+	}
+
+	var values []string
+	for i, group := range metricCfg.GroupBy.Groups {
+		keyValue := ""
+		if i < len(keys) {
+			keyValue = keys[i]
+		}
+		// strip the prefix to keep only the tag value.
+		if group.Type == "TAG" {
+			if prefix := group.Key + "$"; strings.HasPrefix(keyValue, prefix) {
+				keyValue = strings.TrimPrefix(keyValue, prefix)
 			}
-			values = append(values, keyValue)
-			if group.Alias != nil {
-				aliasValue := keyValue
-				if keyValue != "" {
-					if mapped, ok := group.Alias.Map[keyValue]; ok {
-						aliasValue = mapped
-					}
+		}
+		values = append(values, keyValue)
+		if group.Alias != nil {
+			aliasValue := keyValue
+			if keyValue != "" {
+				if mapped, ok := group.Alias.Map[keyValue]; ok {
+					aliasValue = mapped
 				}
-				values = append(values, aliasValue)
 			}
+			values = append(values, aliasValue)
 		}
 	}
-
 	return values
 }
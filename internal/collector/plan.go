@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// PlannedRequest is the Cost Explorer request made for an account and metric
+// on each refresh.
+type PlannedRequest struct {
+	AccountID string
+	Metric    string
+	Input     *costexplorer.GetCostAndUsageInput
+	// Skipped is true when the period is empty and no request is made
+	Skipped bool
+}
+
+// Plan returns the requests a full refresh would make at the clock's time,
+// following the metric selection and overrides of each account, without
+// contacting AWS. Paginated results need additional requests.
+func Plan(cfg *config.Config, clock timeutil.Clock) []PlannedRequest {
+	if clock == nil {
+		clock = timeutil.SystemClock{}
+	}
+
+	var requests []PlannedRequest
+	for _, account := range cfg.TargetAWSAccounts {
+		for _, metricCfg := range account.SelectMetrics(cfg.Metrics) {
+			query := BuildQuery(clock, &metricCfg)
+			requests = append(requests, PlannedRequest{
+				AccountID: account.AccountId,
+				Metric:    metricCfg.MetricName,
+				Input:     query.Input(),
+				Skipped:   !query.EndDate.After(query.StartDate),
+			})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"path"
+)
+
+// SelectMetrics returns the metrics fetched for the account, with the
+// account's overrides applied.
+func (a *AWSAccount) SelectMetrics(metrics []MetricConfig) []MetricConfig {
+	var selected []MetricConfig
+	for _, m := range metrics {
+		if a.selects(&m) {
+			selected = append(selected, a.Override(m))
+		}
+	}
+	return selected
+}
+
+// selects reports whether the metric is fetched for the account
+func (a *AWSAccount) selects(m *MetricConfig) bool {
+	for name, value := range m.AccountSelector {
+		if v, ok := a.Labels[name]; !ok || v != value {
+			return false
+		}
+	}
+
+	if a.Metrics == nil {
+		return true
+	}
+	if matchAny(a.Metrics.Exclude, m.MetricName) {
+		return false
+	}
+	return len(a.Metrics.Include) == 0 || matchAny(a.Metrics.Include, m.MetricName)
+}
+
+// Override returns the metric with the account's overrides applied. The
+// metric is not modified.
+func (a *AWSAccount) Override(m MetricConfig) MetricConfig {
+	o := a.Overrides
+	if o == nil {
+		return m
+	}
+
+	if o.TagFilters != nil {
+		m.TagFilters = o.TagFilters
+	}
+	if o.DataDelayDays != nil {
+		m.DataDelayDays = *o.DataDelayDays
+	}
+	if o.MergeThreshold != nil && m.GroupBy != nil && m.GroupBy.MergeMinorCost != nil {
+		groupBy := *m.GroupBy
+		merge := *groupBy.MergeMinorCost
+		merge.Threshold = *o.MergeThreshold
+		groupBy.MergeMinorCost = &merge
+		m.GroupBy = &groupBy
+	}
+	return m
+}
+
+// HasQueryOverrides reports whether the account's queries differ from the
+// metrics' queries.
+func (a *AWSAccount) HasQueryOverrides() bool {
+	return a.Overrides != nil && (a.Overrides.TagFilters != nil || a.Overrides.DataDelayDays != nil)
+}
+
+// matchAny reports whether name matches any of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
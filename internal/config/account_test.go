@@ -0,0 +1,203 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectMetrics(t *testing.T) {
+	metrics := []MetricConfig{
+		{MetricName: "aws_daily_cost"},
+		{MetricName: "aws_daily_cost_by_service"},
+		{MetricName: "aws_monthly_cost"},
+		{MetricName: "aws_team_cost", AccountSelector: map[string]string{"team": "platform"}},
+		{MetricName: "aws_prod_team_cost", AccountSelector: map[string]string{"team": "platform", "env": "prod"}},
+	}
+
+	tests := []struct {
+		name    string
+		account AWSAccount
+		want    []string
+	}{
+		{
+			name:    "all metrics without selection",
+			account: AWSAccount{},
+			want:    []string{"aws_daily_cost", "aws_daily_cost_by_service", "aws_monthly_cost"},
+		},
+		{
+			name:    "include glob",
+			account: AWSAccount{Metrics: &AccountMetricsConfig{Include: []string{"aws_daily_*"}}},
+			want:    []string{"aws_daily_cost", "aws_daily_cost_by_service"},
+		},
+		{
+			name:    "exclude glob",
+			account: AWSAccount{Metrics: &AccountMetricsConfig{Exclude: []string{"*_by_service"}}},
+			want:    []string{"aws_daily_cost", "aws_monthly_cost"},
+		},
+		{
+			name: "exclude over include",
+			account: AWSAccount{Metrics: &AccountMetricsConfig{
+				Include: []string{"aws_daily_*", "aws_monthly_cost"},
+				Exclude: []string{"aws_daily_cost"},
+			}},
+			want: []string{"aws_daily_cost_by_service", "aws_monthly_cost"},
+		},
+		{
+			name:    "include matching nothing",
+			account: AWSAccount{Metrics: &AccountMetricsConfig{Include: []string{"gcp_*"}}},
+			want:    nil,
+		},
+		{
+			name:    "account selector",
+			account: AWSAccount{Labels: map[string]string{"team": "platform"}},
+			want:    []string{"aws_daily_cost", "aws_daily_cost_by_service", "aws_monthly_cost", "aws_team_cost"},
+		},
+		{
+			name:    "account selector requiring every label",
+			account: AWSAccount{Labels: map[string]string{"team": "platform", "env": "prod"}},
+			want:    []string{"aws_daily_cost", "aws_daily_cost_by_service", "aws_monthly_cost", "aws_team_cost", "aws_prod_team_cost"},
+		},
+		{
+			name:    "account selector with another value",
+			account: AWSAccount{Labels: map[string]string{"team": "data", "env": "prod"}},
+			want:    []string{"aws_daily_cost", "aws_daily_cost_by_service", "aws_monthly_cost"},
+		},
+		{
+			name: "account selector and include",
+			account: AWSAccount{
+				Labels:  map[string]string{"team": "platform"},
+				Metrics: &AccountMetricsConfig{Include: []string{"*_team_cost"}},
+			},
+			want: []string{"aws_team_cost"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range tt.account.SelectMetrics(metrics) {
+				got = append(got, m.MetricName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectMetrics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	delay := 2
+	threshold := 5.0
+	metricFilters := []TagFilter{{TagKey: "team", TagValues: []string{"platform"}}}
+	accountFilters := []TagFilter{{TagKey: "env", TagValues: []string{"prod"}}}
+
+	merged := func() MetricConfig {
+		return MetricConfig{
+			MetricName:    "aws_daily_cost_by_service",
+			DataDelayDays: 1,
+			TagFilters:    metricFilters,
+			GroupBy: &GroupByConfig{
+				Enabled:        true,
+				MergeMinorCost: &MergeConfig{Enabled: true, Threshold: 1, TagValue: "other"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		overrides         *AccountOverrides
+		metric            MetricConfig
+		wantFilters       []TagFilter
+		wantDelay         int
+		wantThreshold     float64
+		wantQueryOverride bool
+	}{
+		{
+			name:          "no overrides",
+			metric:        merged(),
+			wantFilters:   metricFilters,
+			wantDelay:     1,
+			wantThreshold: 1,
+		},
+		{
+			name:          "empty overrides",
+			overrides:     &AccountOverrides{},
+			metric:        merged(),
+			wantFilters:   metricFilters,
+			wantDelay:     1,
+			wantThreshold: 1,
+		},
+		{
+			name:              "tag filters replaced",
+			overrides:         &AccountOverrides{TagFilters: accountFilters},
+			metric:            merged(),
+			wantFilters:       accountFilters,
+			wantDelay:         1,
+			wantThreshold:     1,
+			wantQueryOverride: true,
+		},
+		{
+			name:              "tag filters removed",
+			overrides:         &AccountOverrides{TagFilters: []TagFilter{}},
+			metric:            merged(),
+			wantFilters:       []TagFilter{},
+			wantDelay:         1,
+			wantThreshold:     1,
+			wantQueryOverride: true,
+		},
+		{
+			name:              "data delay",
+			overrides:         &AccountOverrides{DataDelayDays: &delay},
+			metric:            merged(),
+			wantFilters:       metricFilters,
+			wantDelay:         2,
+			wantThreshold:     1,
+			wantQueryOverride: true,
+		},
+		{
+			// The threshold applies after the query
+			name:          "merge threshold",
+			overrides:     &AccountOverrides{MergeThreshold: &threshold},
+			metric:        merged(),
+			wantFilters:   metricFilters,
+			wantDelay:     1,
+			wantThreshold: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := AWSAccount{Overrides: tt.overrides}
+			original := merged()
+
+			got := account.Override(tt.metric)
+			if !reflect.DeepEqual(got.TagFilters, tt.wantFilters) {
+				t.Errorf("tag filters = %v, want %v", got.TagFilters, tt.wantFilters)
+			}
+			if got.DataDelayDays != tt.wantDelay {
+				t.Errorf("data delay = %d, want %d", got.DataDelayDays, tt.wantDelay)
+			}
+			if got.GroupBy.MergeMinorCost.Threshold != tt.wantThreshold {
+				t.Errorf("merge threshold = %v, want %v", got.GroupBy.MergeMinorCost.Threshold, tt.wantThreshold)
+			}
+			if !reflect.DeepEqual(tt.metric, original) {
+				t.Errorf("Override() modified the metric to %+v", tt.metric)
+			}
+			if account.HasQueryOverrides() != tt.wantQueryOverride {
+				t.Errorf("HasQueryOverrides() = %v, want %v", account.HasQueryOverrides(), tt.wantQueryOverride)
+			}
+		})
+	}
+}
+
+func TestOverrideMergeThresholdWithoutMerge(t *testing.T) {
+	threshold := 5.0
+	account := AWSAccount{Overrides: &AccountOverrides{MergeThreshold: &threshold}}
+
+	for _, groupBy := range []*GroupByConfig{nil, {Enabled: true}} {
+		got := account.Override(MetricConfig{MetricName: "aws_daily_cost", GroupBy: groupBy})
+		if got.GroupBy != groupBy {
+			t.Errorf("Override() group_by = %+v, want %+v unchanged", got.GroupBy, groupBy)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/viper"
+)
+
+// reservedLabels are set by the collector on cost metrics
+var reservedLabels = map[string]bool{
+	"account_id":  true,
+	"charge_type": true,
+	"currency":    true,
+	"hour":        true,
+}
+
+// reservedPaths are the endpoints served besides the metrics, which the
+// metrics path cannot replace
+var reservedPaths = map[string]bool{
+	"/healthz":             true,
+	"/readyz":              true,
+	"/api/v1/costs":        true,
+	"/api/v1/costs/export": true,
+	"/-/refresh":           true,
+}
+
+// Problem is a configuration error located by its YAML path, such as
+// metrics[3].group_by.groups[1].label_name.
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// Check reads the config file and returns all its problems, including the
+// unknown keys ignored by Load. The config is returned even if it has
+// problems. An error is returned if the file cannot be read.
+func Check(path string) (*Config, []Problem, error) {
+	source, metricPaths, err := readSource(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cfg Config
+	if err := newViper(source, nil).Unmarshal(&cfg); err != nil {
+		return nil, []Problem{{Message: err.Error()}}, nil
+	}
+
+	problems := append(unknownKeys(source), cfg.problems()...)
+	return &cfg, relocate(problems, metricPaths), nil
+}
+
+var metricPathPattern = regexp.MustCompile(`metrics\[(\d+)\]`)
+
+// relocate replaces the metric paths of the problems, which refer to the
+// expanded metrics, by their paths in the source.
+func relocate(problems []Problem, metricPaths []string) []Problem {
+	replace := func(s string) string {
+		return metricPathPattern.ReplaceAllStringFunc(s, func(ref string) string {
+			i, err := strconv.Atoi(metricPathPattern.FindStringSubmatch(ref)[1])
+			if err != nil || i >= len(metricPaths) {
+				return ref
+			}
+			return metricPaths[i]
+		})
+	}
+
+	for i := range problems {
+		problems[i].Path = replace(problems[i].Path)
+		problems[i].Message = replace(problems[i].Message)
+	}
+	return problems
+}
+
+// unknownKeys returns the keys of the config source not matching any
+// setting. Defaults and environment variables are not considered.
+func unknownKeys(source map[string]any) []Problem {
+	v := viper.New()
+	_ = v.MergeConfigMap(deepCopy(source).(map[string]any))
+
+	var problems []Problem
+	err := v.UnmarshalExact(&Config{})
+	for _, err := range flattenErrors(err) {
+		var keyErr *mapstructure.DecodeError
+		if !errors.As(err, &keyErr) || !strings.HasPrefix(keyErr.Unwrap().Error(), "has invalid keys: ") {
+			continue
+		}
+		// Errors of the root section are named after the Config type
+		section := keyErr.Name()
+		if section == reflect.TypeOf(Config{}).String() {
+			section = ""
+		}
+		keys := strings.TrimPrefix(keyErr.Unwrap().Error(), "has invalid keys: ")
+		for _, key := range strings.Split(keys, ", ") {
+			problems = append(problems, Problem{
+				Path:    joinPath(section, key),
+				Message: "unknown key",
+			})
+		}
+	}
+	return problems
+}
+
+// flattenErrors returns the leaves of joined errors
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, flattenErrors(e)...)
+		}
+		return errs
+	}
+	if wrapped := errors.Unwrap(err); wrapped != nil {
+		if _, ok := err.(*mapstructure.DecodeError); !ok {
+			return flattenErrors(wrapped)
+		}
+	}
+	return []error{err}
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// problems validates the config tags and the consistency of metric and label
+// names.
+func (c *Config) problems() []Problem {
+	problems := c.fieldProblems()
+
+	if c.Server != nil && reservedPaths[c.Server.MetricsPath] {
+		problems = append(problems, Problem{"server.metrics_path", fmt.Sprintf("%q is reserved for another endpoint", c.Server.MetricsPath)})
+	}
+
+	metricNames := make(map[string]int)
+	for i, m := range c.Metrics {
+		path := fmt.Sprintf("metrics[%d]", i)
+
+		// HOURLY metrics always query the last 24 hours
+		if m.Granularity == "HOURLY" && m.Period != "" {
+			problems = append(problems, Problem{path + ".period", "is not supported with HOURLY granularity"})
+		}
+		if p := emptyPeriodPolicyProblem(&m); p != nil {
+			problems = append(problems, Problem{path + "." + p.Path, p.Message})
+		}
+
+		if m.MetricName != "" {
+			if first, ok := metricNames[m.MetricName]; ok {
+				problems = append(problems, Problem{path + ".metric_name", fmt.Sprintf("duplicates metrics[%d].metric_name %q", first, m.MetricName)})
+			} else {
+				metricNames[m.MetricName] = i
+			}
+			if !model.IsValidLegacyMetricName(m.MetricName) {
+				problems = append(problems, Problem{path + ".metric_name", fmt.Sprintf("%q is not a valid Prometheus metric name", m.MetricName)})
+			}
+		}
+
+		problems = append(problems, c.groupLabelProblems(path, m)...)
+	}
+
+	problems = append(problems, c.accountLabelProblems()...)
+	problems = append(problems, c.accountMetricProblems()...)
+	return problems
+}
+
+// accountMetricProblems checks that the metric patterns of the accounts are
+// valid and match a metric.
+func (c *Config) accountMetricProblems() []Problem {
+	var problems []Problem
+	for i, account := range c.TargetAWSAccounts {
+		if account.Metrics == nil {
+			continue
+		}
+		lists := []struct {
+			key      string
+			patterns []string
+		}{
+			{"include", account.Metrics.Include},
+			{"exclude", account.Metrics.Exclude},
+		}
+		for _, list := range lists {
+			for j, pattern := range list.patterns {
+				path := fmt.Sprintf("target_aws_accounts[%d].metrics.%s[%d]", i, list.key, j)
+				if !validPattern(pattern) {
+					problems = append(problems, Problem{path, fmt.Sprintf("invalid pattern %q", pattern)})
+					continue
+				}
+				if !slices.ContainsFunc(c.Metrics, func(m MetricConfig) bool { return matchAny([]string{pattern}, m.MetricName) }) {
+					problems = append(problems, Problem{path, fmt.Sprintf("%q matches no metric", pattern)})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// groupLabelProblems checks the label names of a metric's groups against each
+// other, the account labels and the labels set by the collector.
+func (c *Config) groupLabelProblems(metricPath string, m MetricConfig) []Problem {
+	if m.GroupBy == nil {
+		return nil
+	}
+
+	accountLabels := make(map[string]bool)
+	for _, account := range c.TargetAWSAccounts {
+		for key := range account.Labels {
+			accountLabels[key] = true
+		}
+	}
+
+	var problems []Problem
+	seen := make(map[string]string)
+	check := func(path, name string) {
+		if name == "" {
+			return
+		}
+		switch {
+		case !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, "__"):
+			problems = append(problems, Problem{path, fmt.Sprintf("%q is not a valid Prometheus label name", name)})
+		case reservedLabels[name]:
+			problems = append(problems, Problem{path, fmt.Sprintf("%q is reserved by the exporter", name)})
+		case accountLabels[name]:
+			problems = append(problems, Problem{path, fmt.Sprintf("%q collides with an account label of target_aws_accounts", name)})
+		case seen[name] != "":
+			problems = append(problems, Problem{path, fmt.Sprintf("%q is already used by %s", name, seen[name])})
+		default:
+			seen[name] = path
+		}
+	}
+
+	for i, group := range m.GroupBy.Groups {
+		path := fmt.Sprintf("%s.group_by.groups[%d]", metricPath, i)
+		check(path+".label_name", group.LabelName)
+		if group.Alias != nil {
+			check(path+".alias.label_name", group.Alias.LabelName)
+		}
+	}
+	return problems
+}
+
+// accountLabelProblems checks the account label names. All accounts must
+// have the same labels as they share the metrics.
+func (c *Config) accountLabelProblems() []Problem {
+	var problems []Problem
+	var firstKeys []string
+
+	for i, account := range c.TargetAWSAccounts {
+		path := fmt.Sprintf("target_aws_accounts[%d].labels", i)
+
+		keys := make([]string, 0, len(account.Labels))
+		for key := range account.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			switch {
+			case !model.LabelName(key).IsValidLegacy() || strings.HasPrefix(key, "__"):
+				problems = append(problems, Problem{path + "." + key, fmt.Sprintf("%q is not a valid Prometheus label name", key)})
+			case reservedLabels[key]:
+				problems = append(problems, Problem{path + "." + key, fmt.Sprintf("%q is reserved by the exporter", key)})
+			}
+		}
+
+		if i == 0 {
+			firstKeys = keys
+		} else if !slices.Equal(keys, firstKeys) {
+			problems = append(problems, Problem{path, fmt.Sprintf("label names [%s] differ from target_aws_accounts[0] [%s]",
+				strings.Join(keys, ", "), strings.Join(firstKeys, ", "))})
+		}
+	}
+	return problems
+}
+
+// fieldProblems runs the validate tags of the config
+func (c *Config) fieldProblems() []Problem {
+	return structProblems(c)
+}
+
+// ValidateMetric checks a metric defined outside of the config file, such as
+// on the command line. Problems are located by the metric's YAML keys.
+func ValidateMetric(m *MetricConfig) error {
+	problems := structProblems(m)
+	if m.Granularity == "HOURLY" && m.Period != "" {
+		problems = append(problems, Problem{"period", "is not supported with HOURLY granularity"})
+	}
+	if p := emptyPeriodPolicyProblem(m); p != nil {
+		problems = append(problems, *p)
+	}
+
+	errs := make([]error, len(problems))
+	for i, p := range problems {
+		errs[i] = errors.New(p.String())
+	}
+	return errors.Join(errs...)
+}
+
+// emptyPeriodPolicyProblem checks that the previous_month policy, which
+// replaces an empty month to date, is not set on other periods.
+func emptyPeriodPolicyProblem(m *MetricConfig) *Problem {
+	if m.EmptyPeriodPolicy == "previous_month" && !m.MonthToDate() {
+		return &Problem{"empty_period_policy", "previous_month is only supported with the month_to_date period"}
+	}
+	return nil
+}
+
+func structProblems(s any) []Problem {
+	validate := validator.New()
+	// Name fields after their YAML keys
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		return name
+	})
+
+	err := validate.Struct(s)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		if err != nil {
+			return []Problem{{Message: err.Error()}}
+		}
+		return nil
+	}
+
+	problems := make([]Problem, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		problems = append(problems, Problem{Path: fieldPath(fe.Namespace()), Message: fieldMessage(fe)})
+	}
+	return problems
+}
+
+// fieldPath converts a validator namespace to a YAML path. Segments named
+// after Go fields are squashed structs and dropped.
+func fieldPath(namespace string) string {
+	segments := strings.Split(namespace, ".")[1:]
+	var path []string
+	for _, segment := range segments {
+		if segment != "" && unicode.IsUpper(rune(segment[0])) {
+			continue
+		}
+		path = append(path, segment)
+	}
+	return strings.Join(path, ".")
+}
+
+var camelBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+func fieldMessage(fe validator.FieldError) string {
+	items := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		other := strings.ToLower(camelBoundary.ReplaceAllString(fe.Param(), "${1}_${2}"))
+		return fmt.Sprintf("is required when %s is set", other)
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.ReplaceAll(fe.Param(), " ", ", "), fmt.Sprint(fe.Value()))
+	case "min":
+		if items {
+			return fmt.Sprintf("must have at least %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		if items {
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "url":
+		return "must be a URL"
+	case "startswith":
+		return fmt.Sprintf("must start with %q", fe.Param())
+	}
+	return fmt.Sprintf("failed the %q check", fe.Tag())
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// elementConfig has one invalid field per group and tag filter element
+const elementConfig = `
+target_aws_accounts:
+  - account_id: "123456789012"
+    assumed_role_name: cost-exporter
+metrics:
+  - metric_name: aws_daily_cost
+    granularity: DAILY
+    metric_type: UnblendedCost
+  - metric_name: aws_service_cost
+    granularity: DAILY
+    metric_type: UnblendedCost
+    tag_filters:
+      - tag_key: team
+        tag_values: [platform]
+      - tag_key: ""
+        tag_values: [data]
+    group_by:
+      enabled: true
+      groups:
+        - type: NOPE
+          key: ""
+          label_name: service
+        - type: DIMENSION
+          key: REGION
+`
+
+func TestCheckElementPaths(t *testing.T) {
+	_, problems, err := Check(writeConfig(t, elementConfig))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	var paths []string
+	for _, p := range problems {
+		paths = append(paths, p.Path)
+	}
+	want := []string{
+		"metrics[1].group_by.groups[0].type",
+		"metrics[1].group_by.groups[0].key",
+		"metrics[1].group_by.groups[1].label_name",
+		"metrics[1].tag_filters[1].tag_key",
+	}
+	for _, path := range want {
+		if !slices.Contains(paths, path) {
+			t.Errorf("Check() problems = %v, want one at %s", problems, path)
+		}
+	}
+	if len(problems) != len(want) {
+		t.Errorf("Check() problems = %v, want %d", problems, len(want))
+	}
+}
+
+func TestValidateMetricElementPaths(t *testing.T) {
+	valid := func() MetricConfig {
+		return MetricConfig{
+			MetricName:  "aws_service_cost",
+			Granularity: "DAILY",
+			MetricType:  "UnblendedCost",
+			TagFilters:  []TagFilter{{TagKey: "team", TagValues: []string{"platform"}}},
+			GroupBy: &GroupByConfig{
+				Enabled: true,
+				Groups:  []GroupConfig{{Type: "DIMENSION", Key: "SERVICE", LabelName: "service"}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *MetricConfig)
+		want   string
+	}{
+		{
+			name:   "valid",
+			modify: func(m *MetricConfig) {},
+		},
+		{
+			name:   "group type",
+			modify: func(m *MetricConfig) { m.GroupBy.Groups[0].Type = "NOPE" },
+			want:   "group_by.groups[0].type: ",
+		},
+		{
+			name:   "empty group key",
+			modify: func(m *MetricConfig) { m.GroupBy.Groups[0].Key = "" },
+			want:   "group_by.groups[0].key: is required",
+		},
+		{
+			name: "missing label name",
+			modify: func(m *MetricConfig) {
+				m.GroupBy.Groups = append(m.GroupBy.Groups, GroupConfig{Type: "DIMENSION", Key: "REGION"})
+			},
+			want: "group_by.groups[1].label_name: is required",
+		},
+		{
+			name:   "empty tag key",
+			modify: func(m *MetricConfig) { m.TagFilters[0].TagKey = "" },
+			want:   "tag_filters[0].tag_key: is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid()
+			tt.modify(&m)
+			err := ValidateMetric(&m)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ValidateMetric() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("ValidateMetric() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMetricEmptyPeriodPolicy(t *testing.T) {
+	tests := []struct {
+		granularity string
+		period      string
+		wantErr     bool
+	}{
+		{"MONTHLY", "", false},
+		{"DAILY", "month_to_date", false},
+		{"DAILY", "", true},
+		{"DAILY", "previous_month", true},
+		{"MONTHLY", "quarter_to_date", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity+" "+tt.period, func(t *testing.T) {
+			m := MetricConfig{
+				MetricName:        "aws_cost",
+				Granularity:       tt.granularity,
+				MetricType:        "UnblendedCost",
+				Period:            tt.period,
+				EmptyPeriodPolicy: "previous_month",
+			}
+			err := ValidateMetric(&m)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMetric() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "empty_period_policy: ") {
+				t.Errorf("ValidateMetric() error = %v, want it at empty_period_policy", err)
+			}
+		})
+	}
+}
+
+func TestCheckReservedMetricsPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"/metrics", false},
+		{"/cost-metrics", false},
+		{"/healthz", true},
+		{"/readyz", true},
+		{"/api/v1/costs", true},
+		{"/api/v1/costs/export", true},
+		{"/-/refresh", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			content := baseConfig + "server:\n  metrics_path: " + tt.path + "\n"
+			_, problems, err := Check(writeConfig(t, content))
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if tt.wantErr {
+				if len(problems) != 1 || problems[0].Path != "server.metrics_path" {
+					t.Errorf("Check() problems = %v, want one at server.metrics_path", problems)
+				}
+			} else if len(problems) != 0 {
+				t.Errorf("Check() problems = %v, want none", problems)
+			}
+		})
+	}
+}
@@ -1,28 +1,156 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Config struct {
-	ExporterPort      int            `mapstructure:"exporter_port" validate:"required,min=1,max=65535"`
-	PollingInterval   time.Duration  `mapstructure:"polling_interval" validate:"required,min=1s"`
-	Metrics           []MetricConfig `mapstructure:"metrics" validate:"required,min=1,dive"`
-	TargetAWSAccounts []AWSAccount   `mapstructure:"target_aws_accounts" validate:"required,min=1"`
+	ExporterPort      int                    `mapstructure:"exporter_port" validate:"required,min=1,max=65535"`
+	PollingInterval   time.Duration          `mapstructure:"polling_interval" validate:"required,min=1s"`
+	Metrics           []MetricConfig         `mapstructure:"metrics" validate:"required,min=1,dive"`
+	TargetAWSAccounts []AWSAccount           `mapstructure:"target_aws_accounts" validate:"required,min=1,dive"`
+	Currency          *CurrencyConfig        `mapstructure:"currency"`
+	Pushgateway       *PushgatewayConfig     `mapstructure:"pushgateway"`
+	RemoteWrite       *RemoteWriteConfig     `mapstructure:"remote_write"`
+	OTLP              *OTLPConfig            `mapstructure:"otlp"`
+	Textfile          *TextfileConfig        `mapstructure:"textfile"`
+	RefreshEndpoint   *RefreshEndpointConfig `mapstructure:"refresh_endpoint"`
+	Server            *ServerConfig          `mapstructure:"server"`
+	ExporterMetrics   ExporterMetricsConfig  `mapstructure:"exporter_metrics"`
+	// WebConfigFile enables TLS and basic authentication, see
+	// https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md
+	WebConfigFile string `mapstructure:"web_config_file"`
+	// DisableHTTPServer runs the exporter with its outputs only
+	DisableHTTPServer bool `mapstructure:"disable_http_server"`
+}
+
+// PushgatewayConfig pushes the metrics to a Prometheus Pushgateway after each
+// refresh.
+type PushgatewayConfig struct {
+	URL        string            `mapstructure:"url" validate:"required,url"`
+	Job        string            `mapstructure:"job" validate:"required"`
+	Grouping   map[string]string `mapstructure:"grouping"`
+	HTTPClient HTTPClientConfig  `mapstructure:",squash"`
+}
+
+// RemoteWriteConfig sends the metrics to a Prometheus remote-write endpoint
+// after each refresh.
+type RemoteWriteConfig struct {
+	URL            string            `mapstructure:"url" validate:"required,url"`
+	ExternalLabels map[string]string `mapstructure:"external_labels"`
+	MaxRetries     int               `mapstructure:"max_retries" validate:"min=0"`
+	MinBackoff     time.Duration     `mapstructure:"min_backoff"`
+	MaxBackoff     time.Duration     `mapstructure:"max_backoff"`
+	QueueCapacity  int               `mapstructure:"queue_capacity" validate:"min=0"`
+	HTTPClient     HTTPClientConfig  `mapstructure:",squash"`
+}
+
+// OTLPConfig exports the metrics to an OpenTelemetry collector after each
+// refresh.
+type OTLPConfig struct {
+	Protocol string            `mapstructure:"protocol" validate:"required,oneof=grpc http"`
+	Endpoint string            `mapstructure:"endpoint" validate:"required,url"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Insecure bool              `mapstructure:"insecure"`
+	Timeout  time.Duration     `mapstructure:"timeout"`
+	TLS      *TLSConfig        `mapstructure:"tls"`
+}
+
+// TextfileConfig writes the metrics to a file after each refresh.
+type TextfileConfig struct {
+	Path string `mapstructure:"path" validate:"required"`
+}
+
+// ServerConfig configures the HTTP server. ListenAddress defaults to
+// exporter_port on all interfaces.
+type ServerConfig struct {
+	ListenAddress string        `mapstructure:"listen_address"`
+	MetricsPath   string        `mapstructure:"metrics_path" validate:"omitempty,startswith=/"`
+	RoutePrefix   string        `mapstructure:"route_prefix" validate:"omitempty,startswith=/"`
+	ReadTimeout   time.Duration `mapstructure:"read_timeout" validate:"min=0"`
+	WriteTimeout  time.Duration `mapstructure:"write_timeout" validate:"min=0"`
+	SystemdSocket bool          `mapstructure:"systemd_socket"`
+}
+
+// ExporterMetricsConfig selects the metrics about the exporter itself served
+// along the cost metrics.
+type ExporterMetricsConfig struct {
+	GoCollector      bool `mapstructure:"go_collector"`
+	ProcessCollector bool `mapstructure:"process_collector"`
+	BuildInfo        bool `mapstructure:"build_info"`
+}
+
+// ListenAddress returns the address the HTTP server listens on
+func (c *Config) ListenAddress() string {
+	if c.Server != nil && c.Server.ListenAddress != "" {
+		return c.Server.ListenAddress
+	}
+	return fmt.Sprintf(":%d", c.ExporterPort)
+}
+
+// RefreshEndpointConfig enables POST /-/refresh to trigger a refresh out of
+// band. Token may only be omitted when the web config defines basic auth users
+// or requires client certificates, and cannot be combined with basic auth.
+type RefreshEndpointConfig struct {
+	Token       string        `mapstructure:"token"`
+	MinInterval time.Duration `mapstructure:"min_interval"`
+}
+
+// HTTPClientConfig configures the HTTP client used to send metrics.
+type HTTPClientConfig struct {
+	Timeout   time.Duration    `mapstructure:"timeout"`
+	BasicAuth *BasicAuthConfig `mapstructure:"basic_auth"`
+	TLS       *TLSConfig       `mapstructure:"tls"`
+}
+
+type BasicAuthConfig struct {
+	Username string `mapstructure:"username" validate:"required"`
+	Password string `mapstructure:"password"`
+}
+
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file" validate:"required_with=KeyFile"`
+	KeyFile            string `mapstructure:"key_file" validate:"required_with=CertFile"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// CurrencyConfig converts costs to a target currency. Rates are expressed in
+// target currency units per unit of the source currency.
+type CurrencyConfig struct {
+	Target    string             `mapstructure:"target" validate:"required"`
+	Rates     map[string]float64 `mapstructure:"rates" validate:"dive,gt=0"`
+	RatesFile string             `mapstructure:"rates_file"`
 }
 
 type MetricConfig struct {
-	MetricName        string         `mapstructure:"metric_name" validate:"required"`
-	MetricDescription string         `mapstructure:"metric_description"`
-	Granularity       string         `mapstructure:"granularity" validate:"required,oneof=DAILY MONTHLY"`
-	DataDelayDays     int            `mapstructure:"data_delay_days" validate:"min=0"`
-	MetricType        string         `mapstructure:"metric_type" validate:"required"`
-	RecordTypes       []string       `mapstructure:"record_types"`
-	GroupBy           *GroupByConfig `mapstructure:"group_by"`
-	TagFilters        []TagFilter    `mapstructure:"tag_filters"`
+	MetricName           string         `mapstructure:"metric_name" validate:"required"`
+	MetricDescription    string         `mapstructure:"metric_description"`
+	Granularity          string         `mapstructure:"granularity" validate:"required,oneof=HOURLY DAILY MONTHLY"`
+	DataDelayDays        int            `mapstructure:"data_delay_days" validate:"min=0"`
+	Period               string         `mapstructure:"period" validate:"omitempty,oneof=daily month_to_date previous_month last_7_days last_30_days week_to_date quarter_to_date year_to_date fiscal_year_to_date"`
+	FiscalYearStartMonth int            `mapstructure:"fiscal_year_start_month" validate:"min=0,max=12"`
+	EmptyPeriodPolicy    string         `mapstructure:"empty_period_policy" validate:"omitempty,oneof=skip zero previous_month"`
+	MetricType           string         `mapstructure:"metric_type" validate:"required"`
+	RecordTypes          []string       `mapstructure:"record_types"`
+	GroupBy              *GroupByConfig `mapstructure:"group_by"`
+	TagFilters           []TagFilter    `mapstructure:"tag_filters" validate:"dive"`
+	// AccountSelector restricts the metric to the accounts having all these
+	// labels
+	AccountSelector map[string]string `mapstructure:"account_selector"`
+}
+
+// MonthToDate reports whether the metric queries the month to date, which is
+// the default period of MONTHLY metrics.
+func (m *MetricConfig) MonthToDate() bool {
+	return m.Period == "month_to_date" || (m.Period == "" && m.Granularity == "MONTHLY")
 }
 
 type GroupByConfig struct {
 	Enabled        bool          `mapstructure:"enabled"`
-	Groups         []GroupConfig `mapstructure:"groups" validate:"max=2"`
+	Groups         []GroupConfig `mapstructure:"groups" validate:"max=2,dive"`
 	MergeMinorCost *MergeConfig  `mapstructure:"merge_minor_cost"`
 }
 
@@ -50,7 +178,50 @@ type TagFilter struct {
 }
 
 type AWSAccount struct {
-	AccountId       string            `mapstructure:"account_id" validate:"required"`
-	AssumedRoleName string            `mapstructure:"assumed_role_name" validate:"required"`
-	Labels          map[string]string `mapstructure:"labels"`
+	AccountId       string                `mapstructure:"account_id" validate:"required"`
+	AssumedRoleName string                `mapstructure:"assumed_role_name" validate:"required"`
+	Currency        string                `mapstructure:"currency"`
+	Labels          map[string]string     `mapstructure:"labels"`
+	Metrics         *AccountMetricsConfig `mapstructure:"metrics"`
+	Overrides       *AccountOverrides     `mapstructure:"overrides"`
+}
+
+// AccountMetricsConfig selects the metrics fetched for an account by name or
+// glob pattern. Without include all metrics are selected, exclude takes
+// precedence.
+type AccountMetricsConfig struct {
+	Include []string `mapstructure:"include"`
+	Exclude []string `mapstructure:"exclude"`
+}
+
+// AccountOverrides replaces metric settings for an account.
+type AccountOverrides struct {
+	TagFilters    []TagFilter `mapstructure:"tag_filters" validate:"dive"`
+	DataDelayDays *int        `mapstructure:"data_delay_days" validate:"omitempty,min=0"`
+	// MergeThreshold applies to the metrics merging minor costs
+	MergeThreshold *float64 `mapstructure:"merge_threshold" validate:"omitempty,min=0"`
+}
+
+// Warnings returns non fatal configuration problems.
+func (c *Config) Warnings() []string {
+	var warnings []string
+
+	// Accounts billed in different currencies end up in the same metrics
+	currencies := make(map[string]bool)
+	for _, account := range c.TargetAWSAccounts {
+		if account.Currency != "" {
+			currencies[account.Currency] = true
+		}
+	}
+	if len(currencies) > 1 && c.Currency == nil {
+		warnings = append(warnings, "target_aws_accounts are billed in mixed currencies and no currency conversion is configured, metrics will aggregate mixed currencies")
+	}
+
+	for i, account := range c.TargetAWSAccounts {
+		if len(account.SelectMetrics(c.Metrics)) == 0 {
+			warnings = append(warnings, fmt.Sprintf("target_aws_accounts[%d] (%s) selects no metric", i, account.AccountId))
+		}
+	}
+
+	return warnings
 }
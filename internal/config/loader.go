@@ -1,40 +1,113 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
 	"strings"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 )
 
+// envPrefix prefixes the environment variables overriding the scalar config
+// keys, e.g. AWS_COST_EXPORTER_EXPORTER_PORT for exporter_port or
+// AWS_COST_EXPORTER_SERVER_LISTEN_ADDRESS for server.listen_address.
+const envPrefix = "AWS_COST_EXPORTER"
+
 // Load reads configuration from the specified YAML file and validates it.
 func Load(path string) (*Config, error) {
-	viper.SetConfigFile(path)
-	viper.SetConfigType("yaml")
-
-	// Support environment variables
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
-
-	// Default values
-	viper.SetDefault("exporter_port", 9000)
-	viper.SetDefault("polling_interval_seconds", 28800)
+	return LoadWithOverrides(path, nil)
+}
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("reading config: %w", err)
+// LoadWithOverrides reads configuration like Load, with overrides taking
+// precedence over environment variables, the file and the defaults. Overrides
+// are keyed by config key, such as server.listen_address.
+func LoadWithOverrides(path string, overrides map[string]any) (*Config, error) {
+	source, metricPaths, err := readSource(path)
+	if err != nil {
+		return nil, err
 	}
 
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := newViper(source, overrides).Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unmarshaling config: %w", err)
 	}
 
 	// Validate config
-	validate := validator.New()
-	if err := validate.Struct(&cfg); err != nil {
-		return nil, fmt.Errorf("validating config: %w", err)
+	if problems := relocate(cfg.problems(), metricPaths); len(problems) > 0 {
+		errs := make([]error, len(problems))
+		for i, p := range problems {
+			errs[i] = errors.New(p.String())
+		}
+		return nil, fmt.Errorf("validating config: %w", errors.Join(errs...))
 	}
 
 	return &cfg, nil
 }
+
+// ParseOverride parses a key=value override, such as polling_interval=1h.
+// Only the keys holding a single value can be overridden.
+func ParseOverride(s string) (key, value string, err error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", fmt.Errorf("%q is not a key=value pair", s)
+	}
+	if !slices.Contains(scalarKeys(reflect.TypeOf(Config{}), ""), key) {
+		return "", "", fmt.Errorf("%q is not a config key holding a single value", key)
+	}
+	return key, value, nil
+}
+
+// newViper returns the settings of a config source, from lowest to highest
+// precedence: defaults, source, environment variables and overrides.
+func newViper(source map[string]any, overrides map[string]any) *viper.Viper {
+	v := viper.New()
+
+	// Environment variables are only looked up for bound keys
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range scalarKeys(reflect.TypeOf(Config{}), "") {
+		_ = v.BindEnv(key)
+	}
+
+	// Default values
+	v.SetDefault("exporter_port", 9000)
+	v.SetDefault("polling_interval", "8h")
+	v.SetDefault("exporter_metrics.go_collector", true)
+	v.SetDefault("exporter_metrics.process_collector", true)
+	v.SetDefault("exporter_metrics.build_info", true)
+
+	_ = v.MergeConfigMap(deepCopy(source).(map[string]any))
+
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
+	return v
+}
+
+// scalarKeys returns the config keys holding a single value, which can be
+// overridden by environment variables. Lists and maps are not included.
+func scalarKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+
+		typ := field.Type
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+
+		switch {
+		case opts == "squash":
+			keys = append(keys, scalarKeys(typ, prefix)...)
+		case typ.Kind() == reflect.Struct:
+			keys = append(keys, scalarKeys(typ, prefix+name+".")...)
+		case typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map:
+		default:
+			keys = append(keys, prefix+name)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+target_aws_accounts:
+  - account_id: "123456789012"
+    assumed_role_name: cost-exporter
+metrics:
+  - metric_name: aws_daily_cost
+    granularity: DAILY
+    metric_type: UnblendedCost
+`
+
+// fileSettings overrides every setting checked by TestLoadPrecedence
+const fileSettings = `
+exporter_port: 9100
+polling_interval: 1h
+server:
+  listen_address: ":9101"
+exporter_metrics:
+  go_collector: false
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// settings are the values checked by TestLoadPrecedence
+type settings struct {
+	port            int
+	pollingInterval time.Duration
+	listenAddress   string
+	goCollector     bool
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides map[string]any
+		want      settings
+	}{
+		{
+			name: "defaults",
+			file: baseConfig,
+			want: settings{port: 9000, pollingInterval: 8 * time.Hour, listenAddress: ":9000", goCollector: true},
+		},
+		{
+			name: "file over defaults",
+			file: baseConfig + fileSettings,
+			want: settings{port: 9100, pollingInterval: time.Hour, listenAddress: ":9101", goCollector: false},
+		},
+		{
+			name: "environment over defaults",
+			file: baseConfig,
+			env: map[string]string{
+				"AWS_COST_EXPORTER_EXPORTER_PORT":                 "9200",
+				"AWS_COST_EXPORTER_POLLING_INTERVAL":              "2h",
+				"AWS_COST_EXPORTER_SERVER_LISTEN_ADDRESS":         ":9201",
+				"AWS_COST_EXPORTER_EXPORTER_METRICS_GO_COLLECTOR": "false",
+			},
+			want: settings{port: 9200, pollingInterval: 2 * time.Hour, listenAddress: ":9201", goCollector: false},
+		},
+		{
+			name: "environment over file",
+			file: baseConfig + fileSettings,
+			env: map[string]string{
+				"AWS_COST_EXPORTER_EXPORTER_PORT":                 "9200",
+				"AWS_COST_EXPORTER_POLLING_INTERVAL":              "2h",
+				"AWS_COST_EXPORTER_SERVER_LISTEN_ADDRESS":         ":9201",
+				"AWS_COST_EXPORTER_EXPORTER_METRICS_GO_COLLECTOR": "true",
+			},
+			want: settings{port: 9200, pollingInterval: 2 * time.Hour, listenAddress: ":9201", goCollector: true},
+		},
+		{
+			name: "unprefixed environment ignored",
+			file: baseConfig + fileSettings,
+			env: map[string]string{
+				"EXPORTER_PORT":         "9200",
+				"SERVER_LISTEN_ADDRESS": ":9201",
+			},
+			want: settings{port: 9100, pollingInterval: time.Hour, listenAddress: ":9101", goCollector: false},
+		},
+		{
+			name: "overrides over environment",
+			file: baseConfig + fileSettings,
+			env: map[string]string{
+				"AWS_COST_EXPORTER_SERVER_LISTEN_ADDRESS":         ":9201",
+				"AWS_COST_EXPORTER_EXPORTER_METRICS_GO_COLLECTOR": "true",
+			},
+			overrides: map[string]any{
+				"server.listen_address":         ":9301",
+				"exporter_metrics.go_collector": false,
+			},
+			want: settings{port: 9100, pollingInterval: time.Hour, listenAddress: ":9301", goCollector: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := LoadWithOverrides(writeConfig(t, tt.file), tt.overrides)
+			if err != nil {
+				t.Fatalf("LoadWithOverrides() error = %v", err)
+			}
+
+			got := settings{
+				port:            cfg.ExporterPort,
+				pollingInterval: cfg.PollingInterval,
+				listenAddress:   cfg.ListenAddress(),
+				goCollector:     cfg.ExporterMetrics.GoCollector,
+			}
+			if got != tt.want {
+				t.Errorf("settings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// envPattern matches ${NAME} and ${NAME:-default} references, $${ escapes
+// a literal ${.
+var envPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// readSource reads a config file into a map, with its environment variables
+// expanded, its included files merged, its metric templates expanded and its
+// metric_defaults applied to each metric. It also returns the source path of
+// each metric.
+func readSource(path string) (map[string]any, []string, error) {
+	source, err := readFile(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metrics, _ := source["metrics"].([]any)
+	metrics, metricPaths, err := expandTemplates(metrics)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading config: %w", err)
+	}
+	if metrics != nil {
+		source["metrics"] = metrics
+	}
+
+	if defaults, ok := source["metric_defaults"]; ok {
+		delete(source, "metric_defaults")
+		defaultsMap, ok := defaults.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("reading config: metric_defaults: expected a mapping")
+		}
+		for _, metric := range metrics {
+			if metricMap, ok := metric.(map[string]any); ok {
+				mergeDefaults(metricMap, defaultsMap)
+			}
+		}
+	}
+
+	return source, metricPaths, nil
+}
+
+// readFile reads a config file and the files it includes. Includes are
+// resolved relative to the including file, glob patterns are expanded in
+// lexical order. stack holds the files being read to detect cycles.
+func readFile(path string, stack []string) (map[string]any, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	for _, p := range stack {
+		if p == absPath {
+			return nil, fmt.Errorf("reading config: %s includes itself", path)
+		}
+	}
+	stack = append(stack, absPath)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	source := make(map[string]any)
+	if err := yaml.Unmarshal(data, &source); err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	if source == nil {
+		source = make(map[string]any)
+	}
+
+	expanded, err := expandEnv(source)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	source = expanded.(map[string]any)
+
+	includes, err := includePatterns(source["include"])
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	delete(source, "include")
+
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("reading config %s: include %s: %w", path, pattern, err)
+		}
+		// Globs may match nothing, plain paths must exist
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			included, err := readFile(match, stack)
+			if err != nil {
+				return nil, err
+			}
+			mergeInclude(source, included)
+		}
+	}
+
+	return source, nil
+}
+
+// includePatterns returns the include setting, a path or a list of paths
+func includePatterns(value any) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []any:
+		patterns := make([]string, 0, len(value))
+		for _, item := range value {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include: expected a list of paths")
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	}
+	return nil, fmt.Errorf("include: expected a path or a list of paths")
+}
+
+// expandEnv replaces the environment variable references of all string
+// values. Referencing an unset variable without default is an error.
+func expandEnv(value any) (any, error) {
+	switch value := value.(type) {
+	case string:
+		var missing []string
+		expanded := envPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			if ref == "$${" {
+				return "${"
+			}
+			match := envPattern.FindStringSubmatch(ref)
+			if v, ok := os.LookupEnv(match[1]); ok {
+				return v
+			}
+			if match[2] != "" {
+				return match[3]
+			}
+			missing = append(missing, match[1])
+			return ""
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+		}
+		return expanded, nil
+
+	case map[string]any:
+		for key, item := range value {
+			expanded, err := expandEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = expanded
+		}
+	case []any:
+		for i, item := range value {
+			expanded, err := expandEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = expanded
+		}
+	}
+	return value, nil
+}
+
+// mergeInclude merges an included file into dst. Lists are appended, maps
+// merged and scalars of dst take precedence.
+func mergeInclude(dst, src map[string]any) {
+	for key, srcValue := range src {
+		dstValue, ok := dst[key]
+		if !ok {
+			dst[key] = srcValue
+			continue
+		}
+		switch dstValue := dstValue.(type) {
+		case []any:
+			if srcList, ok := srcValue.([]any); ok {
+				dst[key] = append(dstValue, srcList...)
+			}
+		case map[string]any:
+			if srcMap, ok := srcValue.(map[string]any); ok {
+				mergeInclude(dstValue, srcMap)
+			}
+		}
+	}
+}
+
+// mergeDefaults sets the defaults missing from a metric. Maps are merged,
+// lists and scalars set by the metric replace the defaults.
+func mergeDefaults(metric, defaults map[string]any) {
+	for key, defaultValue := range defaults {
+		value, ok := metric[key]
+		if !ok {
+			metric[key] = deepCopy(defaultValue)
+			continue
+		}
+		valueMap, ok := value.(map[string]any)
+		defaultMap, isMap := defaultValue.(map[string]any)
+		if ok && isMap {
+			mergeDefaults(valueMap, defaultMap)
+		}
+	}
+}
+
+// deepCopy copies the maps and lists of a YAML value so the defaults are not
+// shared between metrics.
+func deepCopy(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(value))
+		for key, item := range value {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(value))
+		for i, item := range value {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	}
+	return value
+}
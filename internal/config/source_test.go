@@ -0,0 +1,231 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files relative to a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func accountIDs(t *testing.T, source map[string]any) []string {
+	t.Helper()
+	accounts, _ := source["target_aws_accounts"].([]any)
+	var ids []string
+	for _, account := range accounts {
+		ids = append(ids, account.(map[string]any)["account_id"].(string))
+	}
+	return ids
+}
+
+func TestReadSourceIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"etc/config.yaml": `
+include:
+  - accounts.d/*.yaml
+  - ../shared/common.yaml
+  - none.d/*.yaml
+polling_interval: 1h
+target_aws_accounts:
+  - account_id: "111111111111"
+`,
+		// Included files are read in lexical order, their includes resolved
+		// relative to themselves
+		"etc/accounts.d/b.yaml": `
+target_aws_accounts:
+  - account_id: "333333333333"
+`,
+		"etc/accounts.d/a.yaml": `
+include: nested/more.yaml
+target_aws_accounts:
+  - account_id: "222222222222"
+`,
+		"etc/accounts.d/nested/more.yaml": `
+target_aws_accounts:
+  - account_id: "444444444444"
+`,
+		"shared/common.yaml": `
+polling_interval: 2h
+exporter_port: 9100
+`,
+	})
+
+	source, _, err := readSource(filepath.Join(dir, "etc", "config.yaml"))
+	if err != nil {
+		t.Fatalf("readSource() error = %v", err)
+	}
+
+	want := []string{"111111111111", "222222222222", "444444444444", "333333333333"}
+	if got := accountIDs(t, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts = %v, want %v", got, want)
+	}
+	// Scalars of the including file take precedence
+	if source["polling_interval"] != "1h" || source["exporter_port"] != 9100 {
+		t.Errorf("polling_interval = %v, exporter_port = %v, want 1h and 9100",
+			source["polling_interval"], source["exporter_port"])
+	}
+	if _, ok := source["include"]; ok {
+		t.Error("include is left in the source")
+	}
+}
+
+func TestReadSourceIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "self include",
+			files: map[string]string{"config.yaml": "include: config.yaml\n"},
+			want:  "includes itself",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"config.yaml": "include: sub/a.yaml\n",
+				"sub/a.yaml":  "include: b.yaml\n",
+				"sub/b.yaml":  "include: ../config.yaml\n",
+			},
+			want: "includes itself",
+		},
+		{
+			name:  "missing file",
+			files: map[string]string{"config.yaml": "include: missing.yaml\n"},
+			want:  "missing.yaml",
+		},
+		{
+			name:  "invalid include",
+			files: map[string]string{"config.yaml": "include: {path: a.yaml}\n"},
+			want:  "expected a path or a list of paths",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, _, err := readSource(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readSource() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("COST_ROLE", "cost-exporter")
+	t.Setenv("COST_EMPTY", "")
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "${COST_ROLE}", want: "cost-exporter"},
+		{value: "arn:aws:iam::role/${COST_ROLE}-${COST_ROLE}", want: "arn:aws:iam::role/cost-exporter-cost-exporter"},
+		{value: "${COST_UNSET:-default}", want: "default"},
+		{value: "${COST_UNSET:-}", want: ""},
+		{value: "${COST_EMPTY:-default}", want: ""},
+		{value: "$${COST_ROLE}", want: "${COST_ROLE}"},
+		{value: "$COST_ROLE", want: "$COST_ROLE"},
+		{value: "${COST_UNSET}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := expandEnv(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("expandEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSourceExpandsEnvInIncludes(t *testing.T) {
+	t.Setenv("COST_INCLUDE", "accounts.yaml")
+	t.Setenv("COST_ROLE", "cost-exporter")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "include: ${COST_INCLUDE}\n",
+		"accounts.yaml": `
+target_aws_accounts:
+  - account_id: "111111111111"
+    assumed_role_name: ${COST_ROLE}
+`,
+	})
+
+	source, _, err := readSource(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("readSource() error = %v", err)
+	}
+	account := source["target_aws_accounts"].([]any)[0].(map[string]any)
+	if account["assumed_role_name"] != "cost-exporter" {
+		t.Errorf("assumed_role_name = %v, want cost-exporter", account["assumed_role_name"])
+	}
+}
+
+func TestReadSourceMetricDefaults(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+metric_defaults:
+  metric_type: AmortizedCost
+  record_types: [Usage]
+  group_by:
+    enabled: true
+metrics:
+  - metric_name: aws_daily_cost
+  - metric_name: aws_hourly_cost
+  - metric_name: aws_monthly_cost
+    metric_type: UnblendedCost
+    record_types: [Usage, Credit]
+    group_by:
+      merge_minor_cost:
+        enabled: true
+`})
+
+	source, _, err := readSource(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("readSource() error = %v", err)
+	}
+	if _, ok := source["metric_defaults"]; ok {
+		t.Error("metric_defaults is left in the source")
+	}
+
+	metrics := source["metrics"].([]any)
+	daily := metrics[0].(map[string]any)
+	monthly := metrics[2].(map[string]any)
+	if daily["metric_type"] != "AmortizedCost" || monthly["metric_type"] != "UnblendedCost" {
+		t.Errorf("metric types = %v, %v, want the default then the metric's", daily["metric_type"], monthly["metric_type"])
+	}
+	// Lists replace the defaults, maps are merged
+	if got := monthly["record_types"]; !reflect.DeepEqual(got, []any{"Usage", "Credit"}) {
+		t.Errorf("record_types = %v, want [Usage Credit]", got)
+	}
+	groupBy := monthly["group_by"].(map[string]any)
+	if groupBy["enabled"] != true || groupBy["merge_minor_cost"] == nil {
+		t.Errorf("group_by = %v, want the default merged with merge_minor_cost", groupBy)
+	}
+
+	// Defaults are copied to each metric
+	daily["group_by"].(map[string]any)["enabled"] = false
+	if hourly := metrics[1].(map[string]any); hourly["group_by"].(map[string]any)["enabled"] != true {
+		t.Error("metrics share their defaults")
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// matrixKeys are the metric settings a template can expand, in expansion
+// order.
+var matrixKeys = []string{"granularity", "metric_type"}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// expandTemplates replaces the metrics having a matrix with one metric per
+// combination of its values. {{granularity}} and {{metric_type}} in the
+// template's string settings are replaced by the snake case value, e.g.
+// aws_{{granularity}}_cost becomes aws_daily_cost. It returns the expanded
+// metrics and, for each, its path in the source.
+func expandTemplates(metrics []any) ([]any, []string, error) {
+	var expanded []any
+	var paths []string
+
+	for i, metric := range metrics {
+		path := fmt.Sprintf("metrics[%d]", i)
+		metricMap, ok := metric.(map[string]any)
+		if !ok || metricMap["matrix"] == nil {
+			expanded = append(expanded, metric)
+			paths = append(paths, path)
+			continue
+		}
+
+		matrix, err := parseMatrix(metricMap["matrix"])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s.%w", path, err)
+		}
+		template := make(map[string]any, len(metricMap))
+		for key, value := range metricMap {
+			if key != "matrix" {
+				template[key] = value
+			}
+		}
+
+		for _, values := range combinations(matrix) {
+			instance := deepCopy(template).(map[string]any)
+			var names []string
+			for _, key := range matrixKeys {
+				if value, ok := values[key]; ok {
+					instance[key] = value
+					names = append(names, key+"="+value)
+				}
+			}
+			replacePlaceholders(instance, values)
+			expanded = append(expanded, instance)
+			paths = append(paths, fmt.Sprintf("%s{%s}", path, strings.Join(names, ",")))
+		}
+	}
+
+	return expanded, paths, nil
+}
+
+// parseMatrix returns the values of each matrix key
+func parseMatrix(value any) (map[string][]string, error) {
+	matrixMap, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("matrix: expected a mapping")
+	}
+
+	matrix := make(map[string][]string)
+	for key, item := range matrixMap {
+		if !isMatrixKey(key) {
+			return nil, fmt.Errorf("matrix.%s: only %s can be expanded", key, strings.Join(matrixKeys, " and "))
+		}
+		list, ok := item.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("matrix.%s: expected a list of values", key)
+		}
+		for _, v := range list {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("matrix.%s: expected a list of values", key)
+			}
+			matrix[key] = append(matrix[key], s)
+		}
+	}
+	return matrix, nil
+}
+
+func isMatrixKey(key string) bool {
+	for _, k := range matrixKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// combinations returns the cartesian product of the matrix values
+func combinations(matrix map[string][]string) []map[string]string {
+	keys := make([]string, 0, len(matrix))
+	for key := range matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := []map[string]string{{}}
+	for _, key := range keys {
+		var next []map[string]string
+		for _, combination := range result {
+			for _, value := range matrix[key] {
+				c := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[key] = value
+				next = append(next, c)
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// replacePlaceholders substitutes the matrix values in the string settings of
+// a metric, placeholders of keys not in the matrix are left as is.
+func replacePlaceholders(value any, values map[string]string) any {
+	switch value := value.(type) {
+	case string:
+		return placeholderPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			key := placeholderPattern.FindStringSubmatch(ref)[1]
+			if v, ok := values[key]; ok {
+				return snakeCase(v)
+			}
+			return ref
+		})
+	case map[string]any:
+		for key, item := range value {
+			value[key] = replacePlaceholders(item, values)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = replacePlaceholders(item, values)
+		}
+	}
+	return value
+}
+
+// snakeCase converts DAILY to daily and AmortizedCost to amortized_cost
+func snakeCase(s string) string {
+	if strings.ToUpper(s) == s {
+		return strings.ToLower(s)
+	}
+	return strings.ToLower(camelBoundary.ReplaceAllString(s, "${1}_${2}"))
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSourceMatrix(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config.yaml": `
+metrics:
+  - metric_name: aws_total_cost
+    granularity: DAILY
+  - metric_name: aws_{{granularity}}_{{metric_type}}
+    metric_description: "{{ metric_type }} per {{granularity}} and {{unknown}}"
+    group_by:
+      groups:
+        - type: DIMENSION
+          key: SERVICE
+          label_name: "{{granularity}}_service"
+    matrix:
+      metric_type: [UnblendedCost, AmortizedCost]
+      granularity: [DAILY, MONTHLY]
+`})
+
+	source, paths, err := readSource(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("readSource() error = %v", err)
+	}
+
+	type instance struct {
+		name, description, granularity, metricType, labelName string
+	}
+	var got []instance
+	for _, metric := range source["metrics"].([]any) {
+		m := metric.(map[string]any)
+		if _, ok := m["matrix"]; ok {
+			t.Errorf("%v keeps its matrix", m["metric_name"])
+		}
+		i := instance{name: m["metric_name"].(string), granularity: m["granularity"].(string)}
+		i.description, _ = m["metric_description"].(string)
+		i.metricType, _ = m["metric_type"].(string)
+		if groupBy, ok := m["group_by"].(map[string]any); ok {
+			i.labelName = groupBy["groups"].([]any)[0].(map[string]any)["label_name"].(string)
+		}
+		got = append(got, i)
+	}
+
+	// Combinations nest the matrix keys in alphabetical order, each value
+	// in its listed order
+	want := []instance{
+		{"aws_total_cost", "", "DAILY", "", ""},
+		{"aws_daily_unblended_cost", "unblended_cost per daily and {{unknown}}", "DAILY", "UnblendedCost", "daily_service"},
+		{"aws_daily_amortized_cost", "amortized_cost per daily and {{unknown}}", "DAILY", "AmortizedCost", "daily_service"},
+		{"aws_monthly_unblended_cost", "unblended_cost per monthly and {{unknown}}", "MONTHLY", "UnblendedCost", "monthly_service"},
+		{"aws_monthly_amortized_cost", "amortized_cost per monthly and {{unknown}}", "MONTHLY", "AmortizedCost", "monthly_service"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("metrics = %+v, want %+v", got, want)
+	}
+
+	wantPaths := []string{
+		"metrics[0]",
+		"metrics[1]{granularity=DAILY,metric_type=UnblendedCost}",
+		"metrics[1]{granularity=DAILY,metric_type=AmortizedCost}",
+		"metrics[1]{granularity=MONTHLY,metric_type=UnblendedCost}",
+		"metrics[1]{granularity=MONTHLY,metric_type=AmortizedCost}",
+	}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("paths = %v, want %v", paths, wantPaths)
+	}
+}
+
+func TestReadSourceMatrixErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix string
+		want   string
+	}{
+		{"unsupported key", "{metric_name: [a, b]}", "metrics[0].matrix.metric_name: only granularity and metric_type can be expanded"},
+		{"empty list", "{granularity: []}", "metrics[0].matrix.granularity: expected a list of values"},
+		{"scalar", "{granularity: DAILY}", "metrics[0].matrix.granularity: expected a list of values"},
+		{"not a mapping", "[DAILY]", "metrics[0].matrix: expected a mapping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"config.yaml": `
+metrics:
+  - metric_name: aws_{{granularity}}_cost
+    matrix: ` + tt.matrix + "\n"})
+			_, _, err := readSource(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readSource() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMatrixDuplicateNames(t *testing.T) {
+	// The template name lacks the metric_type placeholder
+	_, problems, err := Check(writeConfig(t, baseConfig+`
+  - metric_name: aws_{{granularity}}_cost
+    granularity: DAILY
+    metric_type: UnblendedCost
+    matrix:
+      granularity: [DAILY, MONTHLY]
+      metric_type: [UnblendedCost, AmortizedCost]
+`))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	want := []Problem{
+		{
+			Path:    "metrics[1]{granularity=DAILY,metric_type=UnblendedCost}.metric_name",
+			Message: `duplicates metrics[0].metric_name "aws_daily_cost"`,
+		},
+		{
+			Path:    "metrics[1]{granularity=DAILY,metric_type=AmortizedCost}.metric_name",
+			Message: `duplicates metrics[0].metric_name "aws_daily_cost"`,
+		},
+		{
+			Path:    "metrics[1]{granularity=MONTHLY,metric_type=AmortizedCost}.metric_name",
+			Message: `duplicates metrics[1]{granularity=MONTHLY,metric_type=UnblendedCost}.metric_name "aws_monthly_cost"`,
+		},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("Check() problems = %v, want %v", problems, want)
+	}
+}
@@ -0,0 +1,119 @@
+package currency
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// Converter converts amounts to a target currency using static rates from
+// the config, overridden by rates read from an optional rates file.
+type Converter struct {
+	mu          sync.RWMutex
+	target      string
+	staticRates map[string]float64
+	fileRates   map[string]float64
+	ratesFile   string
+	modTime     time.Time
+}
+
+// New returns a converter for cfg, or nil when no conversion is configured.
+func New(cfg *config.CurrencyConfig) (*Converter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	c := &Converter{
+		target:      strings.ToUpper(cfg.Target),
+		staticRates: normalizeRates(cfg.Rates),
+		ratesFile:   cfg.RatesFile,
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Target returns the currency amounts are converted to.
+func (c *Converter) Target() string {
+	return c.target
+}
+
+// Reload reads the rates file again if it changed since the last load.
+func (c *Converter) Reload() error {
+	if c.ratesFile == "" {
+		return nil
+	}
+
+	info, err := os.Stat(c.ratesFile)
+	if err != nil {
+		return fmt.Errorf("reading rates file: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := info.ModTime().Equal(c.modTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.ratesFile)
+	if err != nil {
+		return fmt.Errorf("reading rates file: %w", err)
+	}
+
+	var rates map[string]float64
+	if err := yaml.Unmarshal(data, &rates); err != nil {
+		return fmt.Errorf("parsing rates file %s: %w", c.ratesFile, err)
+	}
+	for unit, rate := range rates {
+		if rate <= 0 {
+			return fmt.Errorf("parsing rates file %s: rate for %s must be positive", c.ratesFile, unit)
+		}
+	}
+
+	c.mu.Lock()
+	c.fileRates = normalizeRates(rates)
+	c.modTime = info.ModTime()
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Convert returns amount converted from unit to the target currency. Amounts
+// without unit are assumed to be in the target currency already.
+func (c *Converter) Convert(amount float64, unit string) (float64, error) {
+	unit = strings.ToUpper(unit)
+	if unit == "" || unit == c.target {
+		return amount, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if rate, ok := c.fileRates[unit]; ok {
+		return amount * rate, nil
+	}
+	if rate, ok := c.staticRates[unit]; ok {
+		return amount * rate, nil
+	}
+
+	return 0, fmt.Errorf("no rate from %s to %s", unit, c.target)
+}
+
+// normalizeRates upper cases currency codes, config keys being lower cased
+// by viper.
+func normalizeRates(rates map[string]float64) map[string]float64 {
+	normalized := make(map[string]float64, len(rates))
+	for unit, rate := range rates {
+		normalized[strings.ToUpper(unit)] = rate
+	}
+	return normalized
+}
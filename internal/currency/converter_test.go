@@ -0,0 +1,121 @@
+package currency
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+func writeRates(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	ratesFile := filepath.Join(t.TempDir(), "rates.yaml")
+	writeRates(t, ratesFile, "usd: 0.9\n", time.Now())
+
+	c, err := New(&config.CurrencyConfig{
+		Target:    "eur",
+		Rates:     map[string]float64{"usd": 0.5, "gbp": 1.2},
+		RatesFile: ratesFile,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if c.Target() != "EUR" {
+		t.Errorf("Target() = %s, want EUR", c.Target())
+	}
+
+	tests := []struct {
+		name    string
+		unit    string
+		want    float64
+		wantErr bool
+	}{
+		{name: "rates file over static rate", unit: "USD", want: 90},
+		{name: "static rate", unit: "GBP", want: 120},
+		{name: "lower case unit", unit: "gbp", want: 120},
+		{name: "target currency", unit: "Eur", want: 100},
+		{name: "no unit", unit: "", want: 100},
+		{name: "missing rate", unit: "JPY", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Convert(100, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Convert() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	ratesFile := filepath.Join(t.TempDir(), "rates.yaml")
+	loaded := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	writeRates(t, ratesFile, "USD: 0.9\n", loaded)
+
+	c, err := New(&config.CurrencyConfig{Target: "EUR", RatesFile: ratesFile})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	convert := func() float64 {
+		t.Helper()
+		got, err := c.Convert(100, "USD")
+		if err != nil {
+			t.Fatalf("Convert() error = %v", err)
+		}
+		return got
+	}
+
+	// The file is only read again when its modification time changes
+	writeRates(t, ratesFile, "USD: 0.8\n", loaded)
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := convert(); got != 90 {
+		t.Errorf("Convert() with an unchanged file = %v, want 90", got)
+	}
+
+	writeRates(t, ratesFile, "USD: 0.8\n", loaded.Add(time.Hour))
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := convert(); got != 80 {
+		t.Errorf("Convert() with a changed file = %v, want 80", got)
+	}
+
+	// Invalid files keep the previous rates
+	writeRates(t, ratesFile, "USD: 0\n", loaded.Add(2*time.Hour))
+	if err := c.Reload(); err == nil {
+		t.Error("Reload() of a zero rate succeeded, want error")
+	}
+	if got := convert(); got != 80 {
+		t.Errorf("Convert() after a failed reload = %v, want 80", got)
+	}
+}
+
+func TestNewRejectsInvalidRates(t *testing.T) {
+	for _, content := range []string{"USD: 0\n", "USD: -1.1\n", "USD: [1]\n"} {
+		t.Run(content, func(t *testing.T) {
+			ratesFile := filepath.Join(t.TempDir(), "rates.yaml")
+			writeRates(t, ratesFile, content, time.Now())
+			if _, err := New(&config.CurrencyConfig{Target: "EUR", RatesFile: ratesFile}); err == nil {
+				t.Errorf("New() with rates %q succeeded, want error", content)
+			}
+		})
+	}
+}
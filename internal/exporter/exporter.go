@@ -5,23 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"runtime"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/exporter-toolkit/web"
 
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
 	"github.com/ydelafollye/aws-cost-exporter-go/internal/server"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
 )
 
 type Exporter struct {
 	config    *config.Config
 	collector *collector.CostCollector
+	registry  *prometheus.Registry
+	outputs   []Output
+	writer    *outputWriter
 	server    *server.Server
 	logger    *slog.Logger
 }
 
-func New(cfg *config.Config, logger *slog.Logger) (*Exporter, error) {
+// New creates an exporter. A nil clock uses the system clock, a nil logger the
+// default logger.
+func New(cfg *config.Config, clock timeutil.Clock, logger *slog.Logger) (*Exporter, error) {
 	if cfg == nil {
 		return nil, errors.New("config is required")
 	}
@@ -30,35 +39,134 @@ func New(cfg *config.Config, logger *slog.Logger) (*Exporter, error) {
 	}
 
 	// Init collector
-	coll, err := collector.New(cfg, logger.With("component", "collector"))
+	coll, err := collector.New(cfg, clock, logger.With("component", "collector"))
 	if err != nil {
 		return nil, fmt.Errorf("creating collector: %w", err)
 	}
 
-	// Record collector into Prometheus
-	if err := prometheus.Register(coll); err != nil {
-		// Ignore error if already registered
-		var alreadyRegistered prometheus.AlreadyRegisteredError
-		if !errors.As(err, &alreadyRegistered) {
-			return nil, fmt.Errorf("registering collector: %w", err)
+	// Record collector into a dedicated registry served on /metrics
+	registry, err := newRegistry(cfg, coll)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create outputs, gathering the collector alone
+	gatherer := prometheus.NewRegistry()
+	if err := gatherer.Register(coll); err != nil {
+		return nil, fmt.Errorf("registering collector: %w", err)
+	}
+
+	var outputs []Output
+	if cfg.Pushgateway != nil {
+		output, err := NewPushgatewayOutput(cfg.Pushgateway, coll)
+		if err != nil {
+			return nil, fmt.Errorf("creating pushgateway output: %w", err)
+		}
+		outputs = append(outputs, output)
+	}
+
+	if cfg.RemoteWrite != nil {
+		output, err := NewRemoteWriteOutput(cfg.RemoteWrite, gatherer, coll, logger.With("component", "remote_write"))
+		if err != nil {
+			return nil, fmt.Errorf("creating remote write output: %w", err)
 		}
+		outputs = append(outputs, output)
 	}
 
+	if cfg.OTLP != nil {
+		output, err := NewOTLPOutput(cfg.OTLP, cfg.TargetAWSAccounts, gatherer, coll)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP output: %w", err)
+		}
+		outputs = append(outputs, output)
+	}
+
+	if cfg.Textfile != nil {
+		outputs = append(outputs, NewTextfileOutput(cfg.Textfile, gatherer))
+	}
+	writer := newOutputWriter(outputs, logger.With("component", "outputs"))
+
 	// Create HTTP server
-	srv := server.New(cfg.ExporterPort, logger.With("component", "server"))
+	var webAuth server.WebAuth
+	if cfg.WebConfigFile != "" {
+		if err := web.Validate(cfg.WebConfigFile); err != nil {
+			return nil, fmt.Errorf("validating web config: %w", err)
+		}
+		webAuth, err = server.ReadWebAuth(cfg.WebConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	opts := server.Options{
+		Gatherer:      registry,
+		ListenAddress: cfg.ListenAddress(),
+		WebConfigFile: cfg.WebConfigFile,
+	}
+	if cfg.Server != nil {
+		opts.MetricsPath = cfg.Server.MetricsPath
+		opts.RoutePrefix = cfg.Server.RoutePrefix
+		opts.ReadTimeout = cfg.Server.ReadTimeout
+		opts.WriteTimeout = cfg.Server.WriteTimeout
+		opts.SystemdSocket = cfg.Server.SystemdSocket
+	}
+	srv := server.New(opts, logger.With("component", "server"))
+	srv.Handle("/api/v1/costs", server.CostsHandler(coll))
+	srv.Handle("/api/v1/costs/export", server.CostsExportHandler(coll))
+	if cfg.RefreshEndpoint != nil {
+		// Basic auth and the bearer token share the Authorization header
+		switch {
+		case cfg.RefreshEndpoint.Token != "" && webAuth.BasicAuth:
+			return nil, errors.New("refresh_endpoint.token cannot be used with basic_auth_users of the web config, which already authenticates the endpoint")
+		case cfg.RefreshEndpoint.Token == "" && !webAuth.Enabled():
+			return nil, errors.New("refresh_endpoint requires a token, or a web config with basic_auth_users or required client certificates")
+		}
+		minInterval := cfg.RefreshEndpoint.MinInterval
+		if minInterval == 0 {
+			minInterval = 5 * time.Minute
+		}
+		refresher := NewRefresher(coll, minInterval, writer.write)
+		srv.Handle("/-/refresh", server.RefreshHandler(refresher, cfg.RefreshEndpoint.Token))
+	}
 
 	return &Exporter{
 		config:    cfg,
 		collector: coll,
+		registry:  registry,
+		outputs:   outputs,
+		writer:    writer,
 		server:    srv,
 		logger:    logger,
 	}, nil
 }
 
+// newRegistry creates the registry of the collector, with the optional Go
+// and process collectors. Build info is registered by RegisterBuildInfo.
+func newRegistry(cfg *config.Config, coll *collector.CostCollector) (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(coll); err != nil {
+		return nil, fmt.Errorf("registering collector: %w", err)
+	}
+
+	var extra []prometheus.Collector
+	if cfg.ExporterMetrics.GoCollector {
+		extra = append(extra, collectors.NewGoCollector())
+	}
+	if cfg.ExporterMetrics.ProcessCollector {
+		extra = append(extra, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	for _, c := range extra {
+		if err := registry.Register(c); err != nil {
+			return nil, fmt.Errorf("registering exporter metrics: %w", err)
+		}
+	}
+
+	return registry, nil
+}
+
 // Run HTTP server and Poller
 func (e *Exporter) Run(ctx context.Context) error {
 	e.logger.Info("starting exporter",
-		"port", e.config.ExporterPort,
+		"listen_address", e.config.ListenAddress(),
 		"polling_interval", e.config.PollingInterval,
 		"accounts", len(e.config.TargetAWSAccounts),
 		"metrics", len(e.config.Metrics),
@@ -68,14 +176,16 @@ func (e *Exporter) Run(ctx context.Context) error {
 	errCh := make(chan error, 2)
 
 	// Start HTTP server
-	go func() {
-		if err := e.server.Start(); err != nil {
-			errCh <- fmt.Errorf("server error: %w", err)
-		}
-	}()
+	if !e.config.DisableHTTPServer {
+		go func() {
+			if err := e.server.Start(); err != nil {
+				errCh <- fmt.Errorf("server error: %w", err)
+			}
+		}()
+	}
 
 	// Start the poller
-	poller := NewPoller(e.collector, e.config.PollingInterval, e.logger.With("component", "poller"))
+	poller := NewPoller(e.collector, e.writer.write, e.config.PollingInterval, e.logger.With("component", "poller"))
 	go func() {
 		if err := poller.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 			errCh <- fmt.Errorf("poller error: %w", err)
@@ -96,6 +206,35 @@ func (e *Exporter) Run(ctx context.Context) error {
 	}
 }
 
+// RunOnce refreshes the collector a single time and writes the outputs,
+// without starting the HTTP server nor the poller.
+func (e *Exporter) RunOnce(ctx context.Context) error {
+	e.logger.Info("running exporter once",
+		"accounts", len(e.config.TargetAWSAccounts),
+		"metrics", len(e.config.Metrics),
+	)
+
+	refreshErr := e.collector.Refresh(ctx)
+
+	var errs []error
+	if refreshErr != nil {
+		errs = append(errs, fmt.Errorf("refresh: %w", refreshErr))
+	}
+	for _, output := range e.outputs {
+		if err := output.Write(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("output %s: %w", output.Name(), err))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := closeOutputs(ctx, e.outputs); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
 // Shutdown all components
 func (e *Exporter) shutdown() {
 	e.logger.Info("shutting down exporter")
@@ -109,8 +248,10 @@ func (e *Exporter) shutdown() {
 		e.logger.Error("server shutdown error", "error", err)
 	}
 
-	// Unregister from Prometheus
-	prometheus.Unregister(e.collector)
+	// Flush and close outputs
+	if err := closeOutputs(ctx, e.outputs); err != nil {
+		e.logger.Error("output shutdown error", "error", err)
+	}
 
 	e.logger.Info("exporter stopped")
 }
@@ -120,6 +261,41 @@ func (e *Exporter) Collector() *collector.CostCollector {
 	return e.collector
 }
 
+// BuildInfo describes the exporter build
+type BuildInfo struct {
+	Version  string
+	Revision string
+}
+
+// RegisterBuildInfo exposes the aws_cost_exporter_build_info gauge, unless
+// build info metrics are disabled.
+func (e *Exporter) RegisterBuildInfo(info BuildInfo) error {
+	if !e.config.ExporterMetrics.BuildInfo {
+		return nil
+	}
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "aws_cost_exporter_build_info",
+		Help: "A metric with a constant '1' value labeled by version, revision and goversion from which aws-cost-exporter was built",
+		ConstLabels: prometheus.Labels{
+			"version":   info.Version,
+			"revision":  info.Revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	gauge.Set(1)
+
+	if err := e.registry.Register(gauge); err != nil {
+		return fmt.Errorf("registering build info: %w", err)
+	}
+	return nil
+}
+
+// Return the registry served on /metrics
+func (e *Exporter) Registry() *prometheus.Registry {
+	return e.registry
+}
+
 // Return exporter config
 func (e *Exporter) Config() *config.Config {
 	return e.config
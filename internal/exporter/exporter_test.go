@@ -0,0 +1,48 @@
+package exporter
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		ExporterPort:      9000,
+		TargetAWSAccounts: []config.AWSAccount{{AccountId: "123456789012", AssumedRoleName: "cost-exporter"}},
+		Metrics: []config.MetricConfig{{
+			MetricName:  "aws_monthly_cost",
+			Granularity: "MONTHLY",
+			MetricType:  "UnblendedCost",
+		}},
+	}
+}
+
+func TestRegisterBuildInfo(t *testing.T) {
+	cfg := testConfig()
+	cfg.ExporterMetrics.BuildInfo = true
+
+	exp, err := New(cfg, nil, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := exp.RegisterBuildInfo(BuildInfo{Version: "1.2.3", Revision: "abc"}); err != nil {
+		t.Fatalf("RegisterBuildInfo() error = %v", err)
+	}
+
+	families, err := exp.Registry().Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	if !names["aws_cost_exporter_build_info"] {
+		t.Error("aws_cost_exporter_build_info is not exposed")
+	}
+	if names["go_build_info"] {
+		t.Error("go_build_info is exposed, want aws_cost_exporter_build_info only")
+	}
+}
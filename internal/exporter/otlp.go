@@ -0,0 +1,265 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// otlpExporter is implemented by the OTLP gRPC and HTTP metric exporters
+type otlpExporter interface {
+	Export(ctx context.Context, rm *metricdata.ResourceMetrics) error
+	Shutdown(ctx context.Context) error
+}
+
+// OTLPOutput exports the collector's metrics over OTLP. Account labels are
+// mapped to resource attributes, so each account is exported as its own
+// resource, and the other labels to data point attributes. Cost data points
+// start with their period, the others with the output creation, so cumulative
+// counters are not read as reset on every export.
+type OTLPOutput struct {
+	exporter      otlpExporter
+	gatherer      prometheus.Gatherer
+	collector     *collector.CostCollector
+	accountLabels map[string]bool
+	startTime     time.Time
+}
+
+func NewOTLPOutput(cfg *config.OTLPConfig, awsAccounts []config.AWSAccount, gatherer prometheus.Gatherer, coll *collector.CostCollector) (*OTLPOutput, error) {
+	exp, err := newOTLPExporter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	accountLabels := map[string]bool{"account_id": true}
+	for _, account := range awsAccounts {
+		for key := range account.Labels {
+			accountLabels[key] = true
+		}
+	}
+
+	return &OTLPOutput{
+		exporter:      exp,
+		gatherer:      gatherer,
+		collector:     coll,
+		accountLabels: accountLabels,
+		startTime:     time.Now(),
+	}, nil
+}
+
+func newOTLPExporter(cfg *config.OTLPConfig) (otlpExporter, error) {
+	ctx := context.Background()
+
+	if cfg.Protocol == "grpc" {
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpointURL(cfg.Endpoint)}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.Headers))
+		}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlpmetricgrpc.WithTimeout(cfg.Timeout))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		} else if cfg.TLS != nil {
+			tlsConfig, err := newTLSConfig(cfg.TLS)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	}
+
+	opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpointURL(cfg.Endpoint)}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(cfg.Headers))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, otlpmetrichttp.WithTimeout(cfg.Timeout))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else if cfg.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+	}
+	return otlpmetrichttp.New(ctx, opts...)
+}
+
+func (o *OTLPOutput) Name() string {
+	return "otlp"
+}
+
+func (o *OTLPOutput) Write(ctx context.Context) error {
+	families, err := o.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+
+	var errs []error
+	for _, rm := range o.resourceMetrics(families, time.Now()) {
+		if err := o.exporter.Export(ctx, rm); err != nil {
+			errs = append(errs, fmt.Errorf("exporting metrics: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Shutdown flushes and closes the OTLP exporter.
+func (o *OTLPOutput) Shutdown(ctx context.Context) error {
+	return o.exporter.Shutdown(ctx)
+}
+
+// resourceMetrics converts the metric families to one ResourceMetrics per
+// account. Metrics without account labels belong to the exporter resource.
+func (o *OTLPOutput) resourceMetrics(families []*dto.MetricFamily, now time.Time) []*metricdata.ResourceMetrics {
+	type resourceData struct {
+		attrs   []attribute.KeyValue
+		metrics map[string]*metricdata.Metrics
+		order   []string
+	}
+	resources := make(map[string]*resourceData)
+	var resourceKeys []string
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			start := o.startTime
+			var resAttrs, pointAttrs []attribute.KeyValue
+			for _, l := range m.GetLabel() {
+				switch {
+				case l.GetName() == "account_id":
+					resAttrs = append(resAttrs, attribute.String("cloud.account.id", l.GetValue()))
+					if period, ok := o.collector.Period(l.GetValue(), family.GetName()); ok {
+						start = period.Start
+					}
+				case o.accountLabels[l.GetName()]:
+					resAttrs = append(resAttrs, attribute.String(l.GetName(), l.GetValue()))
+				default:
+					pointAttrs = append(pointAttrs, attribute.String(l.GetName(), l.GetValue()))
+				}
+			}
+
+			resSet := attribute.NewSet(resAttrs...)
+			key := string(resSet.Encoded(attribute.DefaultEncoder()))
+			res, ok := resources[key]
+			if !ok {
+				res = &resourceData{attrs: resAttrs, metrics: make(map[string]*metricdata.Metrics)}
+				resources[key] = res
+				resourceKeys = append(resourceKeys, key)
+			}
+
+			metric, ok := res.metrics[family.GetName()]
+			if !ok {
+				metric = &metricdata.Metrics{
+					Name:        family.GetName(),
+					Description: family.GetHelp(),
+				}
+				res.metrics[family.GetName()] = metric
+				res.order = append(res.order, family.GetName())
+			}
+			appendDataPoint(metric, family.GetType(), m, attribute.NewSet(pointAttrs...), start, now)
+		}
+	}
+
+	sort.Strings(resourceKeys)
+	var result []*metricdata.ResourceMetrics
+	for _, key := range resourceKeys {
+		res := resources[key]
+		attrs := append([]attribute.KeyValue{
+			attribute.String("service.name", "aws-cost-exporter"),
+			attribute.String("cloud.provider", "aws"),
+		}, res.attrs...)
+
+		var metrics []metricdata.Metrics
+		for _, name := range res.order {
+			metrics = append(metrics, *res.metrics[name])
+		}
+
+		result = append(result, &metricdata.ResourceMetrics{
+			Resource: resource.NewSchemaless(attrs...),
+			ScopeMetrics: []metricdata.ScopeMetrics{{
+				Scope:   instrumentation.Scope{Name: "github.com/ydelafollye/aws-cost-exporter-go"},
+				Metrics: metrics,
+			}},
+		})
+	}
+
+	return result
+}
+
+// appendDataPoint adds a Prometheus metric to the matching OTLP aggregation.
+// Summaries are not supported and skipped.
+func appendDataPoint(metric *metricdata.Metrics, typ dto.MetricType, m *dto.Metric, attrs attribute.Set, start, now time.Time) {
+	switch typ {
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		value := m.GetGauge().GetValue()
+		if typ == dto.MetricType_UNTYPED {
+			value = m.GetUntyped().GetValue()
+		}
+		gauge, _ := metric.Data.(metricdata.Gauge[float64])
+		gauge.DataPoints = append(gauge.DataPoints, metricdata.DataPoint[float64]{
+			Attributes: attrs,
+			StartTime:  start,
+			Time:       now,
+			Value:      value,
+		})
+		metric.Data = gauge
+
+	case dto.MetricType_COUNTER:
+		sum, _ := metric.Data.(metricdata.Sum[float64])
+		sum.Temporality = metricdata.CumulativeTemporality
+		sum.IsMonotonic = true
+		sum.DataPoints = append(sum.DataPoints, metricdata.DataPoint[float64]{
+			Attributes: attrs,
+			StartTime:  start,
+			Time:       now,
+			Value:      m.GetCounter().GetValue(),
+		})
+		metric.Data = sum
+
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		var bounds []float64
+		var counts []uint64
+		var previous uint64
+		for _, b := range h.GetBucket() {
+			bounds = append(bounds, b.GetUpperBound())
+			counts = append(counts, b.GetCumulativeCount()-previous)
+			previous = b.GetCumulativeCount()
+		}
+		// OTLP buckets are not cumulative and end with an overflow bucket
+		counts = append(counts, h.GetSampleCount()-previous)
+
+		hist, _ := metric.Data.(metricdata.Histogram[float64])
+		hist.Temporality = metricdata.CumulativeTemporality
+		hist.DataPoints = append(hist.DataPoints, metricdata.HistogramDataPoint[float64]{
+			Attributes:   attrs,
+			StartTime:    start,
+			Time:         now,
+			Count:        h.GetSampleCount(),
+			Sum:          h.GetSampleSum(),
+			Bounds:       bounds,
+			BucketCounts: counts,
+		})
+		metric.Data = hist
+	}
+}
@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// fakeOTLPExporter records the exported resource metrics
+type fakeOTLPExporter struct {
+	exported []*metricdata.ResourceMetrics
+}
+
+func (f *fakeOTLPExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	f.exported = append(f.exported, rm)
+	return nil
+}
+
+func (f *fakeOTLPExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestOTLPOutputResourceMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	cost := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_monthly_cost", Help: "Monthly cost"},
+		[]string{"account_id", "team", "service"})
+	cost.WithLabelValues("123456789012", "platform", "EC2").Set(42.5)
+	// No period is known for this account
+	cost.WithLabelValues("222222222222", "data", "S3").Set(1)
+	requests := prometheus.NewCounter(prometheus.CounterOpts{Name: "requests_total", Help: "Requests"})
+	requests.Add(3)
+	registry.MustRegister(cost, requests)
+
+	accounts := []config.AWSAccount{
+		{AccountId: "123456789012", Labels: map[string]string{"team": "platform"}},
+		{AccountId: "222222222222", Labels: map[string]string{"team": "data"}},
+	}
+	output, err := NewOTLPOutput(&config.OTLPConfig{Protocol: "http", Endpoint: "http://localhost:4318"},
+		accounts, registry, newPeriodCollector(t))
+	if err != nil {
+		t.Fatalf("NewOTLPOutput() error = %v", err)
+	}
+	fake := &fakeOTLPExporter{}
+	output.exporter = fake
+
+	if err := output.Write(context.Background()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// One resource per account, and the exporter resource
+	resources := make(map[string]*metricdata.ResourceMetrics)
+	for _, rm := range fake.exported {
+		id, _ := rm.Resource.Set().Value("cloud.account.id")
+		resources[id.AsString()] = rm
+	}
+	if len(fake.exported) != 3 || len(resources) != 3 {
+		t.Fatalf("exported %d resources, want 3", len(fake.exported))
+	}
+
+	t.Run("account resource", func(t *testing.T) {
+		rm := resources["123456789012"]
+		if team, _ := rm.Resource.Set().Value("team"); team.AsString() != "platform" {
+			t.Errorf("team resource attribute = %q, want platform", team.AsString())
+		}
+		if provider, _ := rm.Resource.Set().Value("cloud.provider"); provider.AsString() != "aws" {
+			t.Errorf("cloud.provider resource attribute = %q, want aws", provider.AsString())
+		}
+
+		point := onlyGaugePoint(t, rm, "aws_monthly_cost")
+		if point.Value != 42.5 {
+			t.Errorf("value = %v, want 42.5", point.Value)
+		}
+		// Account labels are not repeated on the data points
+		wantAttrs := attribute.NewSet(attribute.String("service", "EC2"))
+		if !point.Attributes.Equals(&wantAttrs) {
+			t.Errorf("data point attributes = %v, want service only", point.Attributes.ToSlice())
+		}
+		if want := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC); !point.StartTime.Equal(want) {
+			t.Errorf("start time = %s, want period start %s", point.StartTime, want)
+		}
+	})
+
+	t.Run("account without period", func(t *testing.T) {
+		point := onlyGaugePoint(t, resources["222222222222"], "aws_monthly_cost")
+		if !point.StartTime.Equal(output.startTime) {
+			t.Errorf("start time = %s, want output creation time %s", point.StartTime, output.startTime)
+		}
+	})
+
+	t.Run("exporter resource", func(t *testing.T) {
+		rm := resources[""]
+		if _, ok := rm.Resource.Set().Value("team"); ok {
+			t.Error("exporter resource has account attributes")
+		}
+		metrics := rm.ScopeMetrics[0].Metrics
+		if len(metrics) != 1 || metrics[0].Name != "requests_total" {
+			t.Fatalf("exporter metrics = %v, want requests_total", metrics)
+		}
+		sum, ok := metrics[0].Data.(metricdata.Sum[float64])
+		if !ok {
+			t.Fatalf("requests_total data = %T, want a sum", metrics[0].Data)
+		}
+		if !sum.IsMonotonic || sum.Temporality != metricdata.CumulativeTemporality {
+			t.Errorf("requests_total sum monotonic %v temporality %s, want a monotonic cumulative sum", sum.IsMonotonic, sum.Temporality)
+		}
+		if len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 3 || !sum.DataPoints[0].StartTime.Equal(output.startTime) {
+			t.Errorf("requests_total data points = %+v, want 3 since the output creation", sum.DataPoints)
+		}
+	})
+}
+
+func onlyGaugePoint(t *testing.T, rm *metricdata.ResourceMetrics, name string) metricdata.DataPoint[float64] {
+	t.Helper()
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 1 || metrics[0].Name != name {
+		t.Fatalf("metrics = %v, want %s only", metrics, name)
+	}
+	gauge, ok := metrics[0].Data.(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("%s data = %+v, want a gauge with one data point", name, metrics[0].Data)
+	}
+	return gauge.DataPoints[0]
+}
@@ -0,0 +1,130 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// Output publishes the collector's metrics after each refresh.
+type Output interface {
+	Name() string
+	Write(ctx context.Context) error
+}
+
+// outputWriter publishes the collector's metrics to the outputs after every
+// refresh, scheduled or out of band. Writes are serialized so an output is
+// never written concurrently.
+type outputWriter struct {
+	mu      sync.Mutex
+	outputs []Output
+	logger  *slog.Logger
+}
+
+func newOutputWriter(outputs []Output, logger *slog.Logger) *outputWriter {
+	return &outputWriter{outputs: outputs, logger: logger}
+}
+
+// write publishes the refreshed metrics to every output, logging failures
+func (w *outputWriter) write(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, output := range w.outputs {
+		if err := output.Write(ctx); err != nil {
+			w.logger.Error("output failed", "output", output.Name(), "error", err)
+		}
+	}
+}
+
+// closeOutputs releases the outputs holding connections.
+func closeOutputs(ctx context.Context, outputs []Output) error {
+	var errs []error
+	for _, output := range outputs {
+		if closer, ok := output.(interface{ Shutdown(context.Context) error }); ok {
+			if err := closer.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("output %s: %w", output.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newHTTPClient creates an HTTP client from the output client config.
+func newHTTPClient(cfg config.HTTPClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	var rt http.RoundTripper = transport
+	if cfg.BasicAuth != nil {
+		rt = &basicAuthRoundTripper{
+			username: cfg.BasicAuth.Username,
+			password: cfg.BasicAuth.Password,
+			next:     transport,
+		}
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &http.Client{Transport: rt, Timeout: timeout}, nil
+}
+
+func newTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+type basicAuthRoundTripper struct {
+	username string
+	password string
+	next     http.RoundTripper
+}
+
+func (rt *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(rt.username, rt.password)
+	return rt.next.RoundTrip(req)
+}
@@ -9,16 +9,20 @@ import (
 )
 
 type Poller struct {
-	collector *collector.CostCollector
-	interval  time.Duration
-	logger    *slog.Logger
+	collector    *collector.CostCollector
+	writeOutputs func(context.Context)
+	interval     time.Duration
+	logger       *slog.Logger
 }
 
-func NewPoller(c *collector.CostCollector, interval time.Duration, logger *slog.Logger) *Poller {
+// NewPoller creates a poller refreshing the collector every interval, then
+// calling writeOutputs.
+func NewPoller(c *collector.CostCollector, writeOutputs func(context.Context), interval time.Duration, logger *slog.Logger) *Poller {
 	return &Poller{
-		collector: c,
-		interval:  interval,
-		logger:    logger,
+		collector:    c,
+		writeOutputs: writeOutputs,
+		interval:     interval,
+		logger:       logger,
 	}
 }
 
@@ -27,6 +31,7 @@ func (p *Poller) Run(ctx context.Context) error {
 	if err := p.collector.Refresh(ctx); err != nil {
 		p.logger.Warn("initial fetch had errors", "error", err)
 	}
+	p.writeOutputs(ctx)
 
 	ticker := time.NewTicker(p.interval)
 	defer ticker.Stop()
@@ -42,6 +47,7 @@ func (p *Poller) Run(ctx context.Context) error {
 			if err := p.collector.Refresh(ctx); err != nil {
 				p.logger.Error("refresh failed", "error", err)
 			}
+			p.writeOutputs(ctx)
 		}
 	}
 }
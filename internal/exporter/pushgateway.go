@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// PushgatewayOutput pushes the collector's metrics to a Pushgateway, replacing
+// all metrics of its job and grouping.
+type PushgatewayOutput struct {
+	pusher *push.Pusher
+}
+
+func NewPushgatewayOutput(cfg *config.PushgatewayConfig, c prometheus.Collector) (*PushgatewayOutput, error) {
+	client, err := newHTTPClient(cfg.HTTPClient)
+	if err != nil {
+		return nil, fmt.Errorf("creating pushgateway client: %w", err)
+	}
+
+	pusher := push.New(cfg.URL, cfg.Job).
+		Collector(c).
+		Client(client)
+	for name, value := range cfg.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	return &PushgatewayOutput{pusher: pusher}, nil
+}
+
+func (o *PushgatewayOutput) Name() string {
+	return "pushgateway"
+}
+
+func (o *PushgatewayOutput) Write(ctx context.Context) error {
+	if err := o.pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("pushing metrics: %w", err)
+	}
+	return nil
+}
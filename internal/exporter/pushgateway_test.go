@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// pushedRequest is a request received by the Pushgateway stand-in
+type pushedRequest struct {
+	method   string
+	path     string
+	username string
+	password string
+	families map[string]*dto.MetricFamily
+}
+
+func newPushgateway(t *testing.T) (*httptest.Server, <-chan pushedRequest) {
+	t.Helper()
+	requests := make(chan pushedRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := pushedRequest{
+			method:   r.Method,
+			path:     r.URL.Path,
+			families: make(map[string]*dto.MetricFamily),
+		}
+		req.username, req.password, _ = r.BasicAuth()
+
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			var family dto.MetricFamily
+			if err := dec.Decode(&family); err != nil {
+				if err != io.EOF {
+					t.Errorf("decoding pushed metrics: %v", err)
+				}
+				break
+			}
+			req.families[family.GetName()] = &family
+		}
+
+		requests <- req
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestPushgatewayOutput(t *testing.T) {
+	srv, requests := newPushgateway(t)
+
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aws_daily_cost",
+		Help: "Daily cost",
+	}, []string{"account_id"})
+	gauge.WithLabelValues("123456789012").Set(42.5)
+
+	output, err := NewPushgatewayOutput(&config.PushgatewayConfig{
+		URL:      srv.URL,
+		Job:      "aws_cost_exporter",
+		Grouping: map[string]string{"instance": "prod", "region": "eu-west-1"},
+		HTTPClient: config.HTTPClientConfig{
+			BasicAuth: &config.BasicAuthConfig{Username: "pusher", Password: "secret"},
+		},
+	}, gauge)
+	if err != nil {
+		t.Fatalf("NewPushgatewayOutput() error = %v", err)
+	}
+
+	if err := output.Write(context.Background()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	req := <-requests
+
+	// Pushes replace all metrics of the group
+	if req.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", req.method)
+	}
+
+	// Grouping labels follow the job in any order
+	segments := strings.Split(strings.TrimPrefix(req.path, "/metrics/"), "/")
+	if len(segments)%2 != 0 {
+		t.Fatalf("path = %s, want /metrics/job/<job>/<label>/<value>...", req.path)
+	}
+	grouping := make(map[string]string)
+	for i := 0; i < len(segments); i += 2 {
+		grouping[segments[i]] = segments[i+1]
+	}
+	wantGrouping := map[string]string{"job": "aws_cost_exporter", "instance": "prod", "region": "eu-west-1"}
+	if segments[0] != "job" || !maps.Equal(grouping, wantGrouping) {
+		t.Errorf("path = %s, want job aws_cost_exporter grouped by instance prod and region eu-west-1", req.path)
+	}
+
+	if req.username != "pusher" || req.password != "secret" {
+		t.Errorf("basic auth = %q:%q, want pusher:secret", req.username, req.password)
+	}
+
+	family, ok := req.families["aws_daily_cost"]
+	if !ok || len(family.GetMetric()) != 1 {
+		t.Fatalf("pushed metrics = %v, want one aws_daily_cost series", req.families)
+	}
+	metric := family.GetMetric()[0]
+	if got := metric.GetGauge().GetValue(); got != 42.5 {
+		t.Errorf("aws_daily_cost = %v, want 42.5", got)
+	}
+	labels := make(map[string]string)
+	for _, l := range metric.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["account_id"] != "123456789012" {
+		t.Errorf("labels = %v, want account_id 123456789012", labels)
+	}
+}
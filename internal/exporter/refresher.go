@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/server"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// refreshTimeout bounds out of band refreshes, which outlive the request
+// triggering them
+const refreshTimeout = 10 * time.Minute
+
+// Refresher runs out of band refreshes of the collector. Concurrent requests
+// for the same scope share a single refresh, and a new refresh is only
+// started minInterval after the previous one, to protect API spend. Like the
+// poller, the outputs are written after each refresh, even a partially failed
+// one.
+type Refresher struct {
+	collector    ScopeRefresher
+	minInterval  time.Duration
+	writeOutputs func(context.Context)
+	clock        timeutil.Clock
+
+	mu       sync.Mutex
+	last     time.Time
+	inflight map[string]*refreshCall
+}
+
+// ScopeRefresher refreshes the data of some accounts and metrics, such as
+// the CostCollector.
+type ScopeRefresher interface {
+	RefreshScope(ctx context.Context, accountIDs, metricNames []string) error
+	ValidateScope(accountIDs, metricNames []string) error
+}
+
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+func NewRefresher(c ScopeRefresher, minInterval time.Duration, writeOutputs func(context.Context)) *Refresher {
+	return &Refresher{
+		collector:    c,
+		minInterval:  minInterval,
+		writeOutputs: writeOutputs,
+		clock:        timeutil.SystemClock{},
+		inflight:     make(map[string]*refreshCall),
+	}
+}
+
+func (r *Refresher) Refresh(ctx context.Context, accountIDs, metricNames []string) error {
+	if err := r.collector.ValidateScope(accountIDs, metricNames); err != nil {
+		return err
+	}
+	key := scopeKey(accountIDs) + "|" + scopeKey(metricNames)
+
+	r.mu.Lock()
+	call, ok := r.inflight[key]
+	if !ok {
+		now := r.clock.Now()
+		if wait := r.minInterval - now.Sub(r.last); !r.last.IsZero() && wait > 0 {
+			r.mu.Unlock()
+			return &server.RateLimitError{RetryAfter: wait}
+		}
+		r.last = now
+
+		call = &refreshCall{done: make(chan struct{})}
+		r.inflight[key] = call
+		go r.run(key, call, accountIDs, metricNames)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Refresher) run(key string, call *refreshCall, accountIDs, metricNames []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	call.err = r.collector.RefreshScope(ctx, accountIDs, metricNames)
+
+	r.mu.Lock()
+	delete(r.inflight, key)
+	r.mu.Unlock()
+	close(call.done)
+
+	// Outputs are written after answering, they may retry for a while
+	r.writeOutputs(ctx)
+}
+
+// scopeKey returns an order independent key of a scope
+func scopeKey(values []string) string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return strings.Join(slices.Compact(sorted), ",")
+}
@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/server"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// fakeScopeRefresher records the refreshed scopes. When release is set,
+// refreshes signal started and block until release is closed.
+type fakeScopeRefresher struct {
+	err     error
+	started chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	scopes []string
+}
+
+func (f *fakeScopeRefresher) RefreshScope(ctx context.Context, accountIDs, metricNames []string) error {
+	f.mu.Lock()
+	f.scopes = append(f.scopes, scopeKey(accountIDs)+"|"+scopeKey(metricNames))
+	f.mu.Unlock()
+
+	if f.release != nil {
+		f.started <- struct{}{}
+		<-f.release
+	}
+	return f.err
+}
+
+func (f *fakeScopeRefresher) ValidateScope(accountIDs, metricNames []string) error {
+	if slices.Contains(accountIDs, "unknown") {
+		return collector.ErrUnknownScope
+	}
+	return nil
+}
+
+func (f *fakeScopeRefresher) refreshed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.scopes)
+}
+
+// newTestRefresher returns a refresher with a 5 minute interval, signaling
+// each output write on the returned channel.
+func newTestRefresher(fake *fakeScopeRefresher, now time.Time) (*Refresher, <-chan struct{}) {
+	writes := make(chan struct{}, 10)
+	r := NewRefresher(fake, 5*time.Minute, func(context.Context) { writes <- struct{}{} })
+	r.clock = timeutil.FixedClock(now)
+	return r, writes
+}
+
+func waitWrite(t *testing.T, writes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-writes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("outputs were not written")
+	}
+}
+
+var refreshStart = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func TestRefresherScope(t *testing.T) {
+	fake := &fakeScopeRefresher{}
+	r, writes := newTestRefresher(fake, refreshStart)
+
+	// Unknown scopes are rejected without refreshing nor rate limiting
+	if err := r.Refresh(context.Background(), []string{"unknown"}, nil); !errors.Is(err, collector.ErrUnknownScope) {
+		t.Fatalf("Refresh() of an unknown account error = %v, want ErrUnknownScope", err)
+	}
+
+	accounts := []string{"222222222222", "111111111111"}
+	metrics := []string{"aws_daily_cost"}
+	if err := r.Refresh(context.Background(), accounts, metrics); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	waitWrite(t, writes)
+
+	want := []string{"111111111111,222222222222|aws_daily_cost"}
+	if got := fake.refreshed(); !slices.Equal(got, want) {
+		t.Errorf("refreshed scopes = %v, want %v", got, want)
+	}
+}
+
+func TestRefresherRateLimit(t *testing.T) {
+	fake := &fakeScopeRefresher{}
+	r, writes := newTestRefresher(fake, refreshStart)
+
+	if err := r.Refresh(context.Background(), nil, nil); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	waitWrite(t, writes)
+
+	// Any scope is rate limited until the interval has elapsed
+	r.clock = timeutil.FixedClock(refreshStart.Add(time.Minute))
+	err := r.Refresh(context.Background(), []string{"111111111111"}, nil)
+	var rateLimit *server.RateLimitError
+	if !errors.As(err, &rateLimit) || rateLimit.RetryAfter != 4*time.Minute {
+		t.Fatalf("Refresh() error = %v, want a rate limit retrying after 4m", err)
+	}
+
+	r.clock = timeutil.FixedClock(refreshStart.Add(5 * time.Minute))
+	if err := r.Refresh(context.Background(), []string{"111111111111"}, nil); err != nil {
+		t.Fatalf("Refresh() after the interval error = %v", err)
+	}
+	waitWrite(t, writes)
+
+	if got := fake.refreshed(); len(got) != 2 {
+		t.Errorf("refreshed scopes = %v, want 2 refreshes", got)
+	}
+}
+
+func TestRefresherCoalescing(t *testing.T) {
+	fake := &fakeScopeRefresher{started: make(chan struct{}), release: make(chan struct{})}
+	r, writes := newTestRefresher(fake, refreshStart)
+
+	first := make(chan error)
+	go func() {
+		first <- r.Refresh(context.Background(), []string{"111111111111", "222222222222"}, nil)
+	}()
+	<-fake.started
+
+	// A request for the same scope, in any order, joins the running refresh
+	// instead of being rate limited. Its canceled context stops waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Refresh(ctx, []string{"222222222222", "111111111111"}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Refresh() of the running scope error = %v, want context.Canceled", err)
+	}
+
+	// Another scope starts a new refresh, which is rate limited
+	var rateLimit *server.RateLimitError
+	if err := r.Refresh(context.Background(), []string{"111111111111"}, nil); !errors.As(err, &rateLimit) {
+		t.Errorf("Refresh() of another scope error = %v, want a rate limit", err)
+	}
+
+	close(fake.release)
+	if err := <-first; err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	waitWrite(t, writes)
+
+	if got := fake.refreshed(); len(got) != 1 {
+		t.Errorf("refreshed scopes = %v, want a single refresh", got)
+	}
+}
+
+func TestRefresherWritesOutputsAfterFailure(t *testing.T) {
+	refreshErr := errors.New("account 111111111111 failed")
+	fake := &fakeScopeRefresher{err: refreshErr}
+	r, writes := newTestRefresher(fake, refreshStart)
+
+	if err := r.Refresh(context.Background(), nil, nil); !errors.Is(err, refreshErr) {
+		t.Fatalf("Refresh() error = %v, want %v", err, refreshErr)
+	}
+	// The data of the other accounts is written, as by the poller
+	waitWrite(t, writes)
+}
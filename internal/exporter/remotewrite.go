@@ -0,0 +1,306 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// RemoteWriteOutput sends the collector's series to a Prometheus remote-write
+// endpoint. Cost samples are timestamped with the start of their period.
+// Requests failing after all retries are queued and sent again before the
+// next refresh.
+type RemoteWriteOutput struct {
+	url            string
+	client         *http.Client
+	gatherer       prometheus.Gatherer
+	collector      *collector.CostCollector
+	externalLabels map[string]string
+	maxRetries     int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	queueCapacity  int
+	logger         *slog.Logger
+
+	mu    sync.Mutex
+	queue [][]byte
+}
+
+// errNonRetryable marks requests rejected by the remote endpoint
+var errNonRetryable = errors.New("non retryable error")
+
+type sample struct {
+	labels    []*dto.LabelPair
+	value     float64
+	timestamp time.Time
+}
+
+func NewRemoteWriteOutput(cfg *config.RemoteWriteConfig, gatherer prometheus.Gatherer, coll *collector.CostCollector, logger *slog.Logger) (*RemoteWriteOutput, error) {
+	client, err := newHTTPClient(cfg.HTTPClient)
+	if err != nil {
+		return nil, fmt.Errorf("creating remote write client: %w", err)
+	}
+
+	o := &RemoteWriteOutput{
+		url:            cfg.URL,
+		client:         client,
+		gatherer:       gatherer,
+		collector:      coll,
+		externalLabels: cfg.ExternalLabels,
+		maxRetries:     cfg.MaxRetries,
+		minBackoff:     cfg.MinBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		queueCapacity:  cfg.QueueCapacity,
+		logger:         logger,
+	}
+	if o.maxRetries == 0 {
+		o.maxRetries = 3
+	}
+	if o.minBackoff == 0 {
+		o.minBackoff = time.Second
+	}
+	if o.maxBackoff == 0 {
+		o.maxBackoff = 30 * time.Second
+	}
+	if o.queueCapacity == 0 {
+		o.queueCapacity = 10
+	}
+
+	return o, nil
+}
+
+func (o *RemoteWriteOutput) Name() string {
+	return "remote_write"
+}
+
+func (o *RemoteWriteOutput) Write(ctx context.Context) error {
+	body, err := o.buildRequest()
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.queue = append(o.queue, body)
+	if dropped := len(o.queue) - o.queueCapacity; dropped > 0 {
+		o.logger.Warn("remote write queue full, dropping oldest requests", "dropped", dropped)
+		o.queue = o.queue[dropped:]
+	}
+
+	var errs []error
+	for len(o.queue) > 0 {
+		err := o.send(ctx, o.queue[0])
+		if err != nil && !errors.Is(err, errNonRetryable) {
+			return errors.Join(append(errs, err)...)
+		}
+		o.queue = o.queue[1:]
+		// Rejected requests would be rejected again
+		if err != nil {
+			o.logger.Error("remote write request rejected, dropping it", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// send posts a request, retrying recoverable errors with exponential backoff
+func (o *RemoteWriteOutput) send(ctx context.Context, body []byte) error {
+	backoff := o.minBackoff
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		err = o.post(ctx, body)
+		if err == nil || errors.Is(err, errNonRetryable) || attempt >= o.maxRetries {
+			return err
+		}
+
+		o.logger.Warn("remote write failed, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, o.maxBackoff)
+	}
+}
+
+func (o *RemoteWriteOutput) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "aws-cost-exporter")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote write returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errNonRetryable, err)
+}
+
+// buildRequest gathers the metrics and encodes them as a snappy compressed
+// remote-write protobuf request.
+func (o *RemoteWriteOutput) buildRequest() ([]byte, error) {
+	families, err := o.gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("gathering metrics: %w", err)
+	}
+
+	now := time.Now()
+	var req []byte
+	for _, family := range families {
+		for _, s := range familySamples(family, now) {
+			s.timestamp = o.sampleTimestamp(family.GetName(), s)
+			req = protowire.AppendTag(req, 1, protowire.BytesType)
+			req = protowire.AppendBytes(req, o.encodeTimeSeries(s))
+		}
+	}
+
+	return snappy.Encode(nil, req), nil
+}
+
+// sampleTimestamp returns the start of the cost period of the sample's account,
+// or the hour of HOURLY samples. Other samples keep their gathering time.
+func (o *RemoteWriteOutput) sampleTimestamp(family string, s sample) time.Time {
+	timestamp := s.timestamp
+	for _, l := range s.labels {
+		switch l.GetName() {
+		case "account_id":
+			if period, ok := o.collector.Period(l.GetValue(), family); ok {
+				timestamp = period.Start
+			}
+		case "hour":
+			if hour, err := time.Parse(time.RFC3339, l.GetValue()); err == nil {
+				return hour
+			}
+		}
+	}
+	return timestamp
+}
+
+func (o *RemoteWriteOutput) encodeTimeSeries(s sample) []byte {
+	labels := make(map[string]string, len(s.labels)+len(o.externalLabels))
+	// Series labels take precedence over external labels
+	for name, value := range o.externalLabels {
+		labels[name] = value
+	}
+	for _, l := range s.labels {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ts []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+
+	var smpl []byte
+	smpl = protowire.AppendTag(smpl, 1, protowire.Fixed64Type)
+	smpl = protowire.AppendFixed64(smpl, math.Float64bits(s.value))
+	smpl = protowire.AppendTag(smpl, 2, protowire.VarintType)
+	smpl = protowire.AppendVarint(smpl, uint64(s.timestamp.UnixMilli()))
+
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+	ts = protowire.AppendBytes(ts, smpl)
+
+	return ts
+}
+
+// familySamples flattens a metric family into samples, expanding histograms
+// and summaries into their series.
+func familySamples(family *dto.MetricFamily, timestamp time.Time) []sample {
+	name := family.GetName()
+	var samples []sample
+
+	add := func(m *dto.Metric, suffix string, value float64, extra ...*dto.LabelPair) {
+		labels := []*dto.LabelPair{{Name: strPtr("__name__"), Value: strPtr(name + suffix)}}
+		labels = append(labels, m.GetLabel()...)
+		labels = append(labels, extra...)
+		samples = append(samples, sample{labels: labels, value: value, timestamp: timestamp})
+	}
+
+	for _, m := range family.GetMetric() {
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			add(m, "", m.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			add(m, "", m.GetGauge().GetValue())
+		case dto.MetricType_UNTYPED:
+			add(m, "", m.GetUntyped().GetValue())
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			for _, b := range h.GetBucket() {
+				add(m, "_bucket", float64(b.GetCumulativeCount()), labelPair("le", formatFloat(b.GetUpperBound())))
+			}
+			add(m, "_bucket", float64(h.GetSampleCount()), labelPair("le", "+Inf"))
+			add(m, "_sum", h.GetSampleSum())
+			add(m, "_count", float64(h.GetSampleCount()))
+		case dto.MetricType_SUMMARY:
+			sm := m.GetSummary()
+			for _, q := range sm.GetQuantile() {
+				add(m, "", q.GetValue(), labelPair("quantile", formatFloat(q.GetQuantile())))
+			}
+			add(m, "_sum", sm.GetSampleSum())
+			add(m, "_count", float64(sm.GetSampleCount()))
+		}
+	}
+
+	return samples
+}
+
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: strPtr(name), Value: strPtr(value)}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,359 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+	"github.com/ydelafollye/aws-cost-exporter-go/pkg/timeutil"
+)
+
+// firstOfMonth makes month to date periods empty, they are not queried
+var firstOfMonth = time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+// newPeriodCollector returns a refreshed collector holding the empty month to
+// date period of aws_monthly_cost, starting on firstOfMonth's day.
+func newPeriodCollector(t *testing.T) *collector.CostCollector {
+	t.Helper()
+	cfg := testConfig()
+	cfg.Metrics[0].Period = "month_to_date"
+	cfg.Metrics[0].EmptyPeriodPolicy = "zero"
+
+	coll, err := collector.New(cfg, timeutil.FixedClock(firstOfMonth), slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("collector.New() error = %v", err)
+	}
+	if err := coll.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	return coll
+}
+
+// writtenSeries is a time series decoded from a remote write request
+type writtenSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp time.Time
+}
+
+// decodeWriteRequest decodes a snappy compressed remote write request
+func decodeWriteRequest(t *testing.T, body []byte) []writtenSeries {
+	t.Helper()
+	req, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("decoding snappy: %v", err)
+	}
+
+	var series []writtenSeries
+	forEachField(t, req, func(num protowire.Number, typ protowire.Type, value []byte) {
+		if num != 1 || typ != protowire.BytesType {
+			return
+		}
+		s := writtenSeries{labels: make(map[string]string)}
+		forEachField(t, value, func(num protowire.Number, typ protowire.Type, value []byte) {
+			switch num {
+			case 1:
+				var name, labelValue string
+				forEachField(t, value, func(num protowire.Number, typ protowire.Type, value []byte) {
+					if num == 1 {
+						name = string(value)
+					} else if num == 2 {
+						labelValue = string(value)
+					}
+				})
+				s.labels[name] = labelValue
+			case 2:
+				forEachField(t, value, func(num protowire.Number, typ protowire.Type, value []byte) {
+					if num == 1 {
+						bits, _ := protowire.ConsumeFixed64(value)
+						s.value = math.Float64frombits(bits)
+					} else if num == 2 {
+						ms, _ := protowire.ConsumeVarint(value)
+						s.timestamp = time.UnixMilli(int64(ms)).UTC()
+					}
+				})
+			}
+		})
+		series = append(series, s)
+	})
+	return series
+}
+
+// forEachField calls fn with each field of a protobuf message. Varint and
+// fixed64 values are passed still encoded.
+func forEachField(t *testing.T, msg []byte, fn func(protowire.Number, protowire.Type, []byte)) {
+	t.Helper()
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatalf("decoding tag: %v", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			v, m := protowire.ConsumeBytes(msg)
+			value, n = v, m
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+			if n >= 0 {
+				value = msg[:n]
+			}
+		}
+		if n < 0 {
+			t.Fatalf("decoding field %d: %v", num, protowire.ParseError(n))
+		}
+		fn(num, typ, value)
+		msg = msg[n:]
+	}
+}
+
+// remoteWriteServer is a remote write endpoint answering each request with
+// the status returned by respond, called with the decoded series.
+type remoteWriteServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	respond  func([]writtenSeries) int
+	requests [][]writtenSeries
+}
+
+func newRemoteWriteServer(t *testing.T, respond func([]writtenSeries) int) *remoteWriteServer {
+	t.Helper()
+	s := &remoteWriteServer{respond: respond}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("headers = %v, want snappy protobuf", r.Header)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		series := decodeWriteRequest(t, body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, series)
+		w.WriteHeader(s.respond(series))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// reset clears the received requests and changes the response
+func (s *remoteWriteServer) reset(respond func([]writtenSeries) int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.respond = respond
+	s.requests = nil
+}
+
+// values returns the value of the first series of each received request
+func (s *remoteWriteServer) values() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]float64, len(s.requests))
+	for i, series := range s.requests {
+		if len(series) > 0 {
+			values[i] = series[0].value
+		}
+	}
+	return values
+}
+
+func respondStatus(status int) func([]writtenSeries) int {
+	return func([]writtenSeries) int { return status }
+}
+
+func newTestRemoteWrite(t *testing.T, cfg *config.RemoteWriteConfig, gatherer prometheus.Gatherer) *RemoteWriteOutput {
+	t.Helper()
+	cfg.MinBackoff = time.Millisecond
+	cfg.MaxBackoff = time.Millisecond
+	output, err := NewRemoteWriteOutput(cfg, gatherer, newPeriodCollector(t), slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("NewRemoteWriteOutput() error = %v", err)
+	}
+	return output
+}
+
+// newCostGauge returns a registry with a single aws_monthly_cost series
+func newCostGauge(value float64) (*prometheus.Registry, prometheus.Gauge) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "aws_monthly_cost",
+		Help:        "Monthly cost",
+		ConstLabels: prometheus.Labels{"account_id": "123456789012"},
+	})
+	gauge.Set(value)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(gauge)
+	return registry, gauge
+}
+
+func TestRemoteWriteOutputEncoding(t *testing.T) {
+	srv := newRemoteWriteServer(t, respondStatus(http.StatusNoContent))
+
+	registry := prometheus.NewRegistry()
+	monthly := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_monthly_cost", Help: "Monthly cost"},
+		[]string{"account_id", "region"})
+	monthly.WithLabelValues("123456789012", "eu-west-1").Set(42.5)
+	hourly := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_hourly_cost", Help: "Hourly cost"},
+		[]string{"account_id", "hour"})
+	hourly.WithLabelValues("123456789012", "2025-03-01T08:00:00Z").Set(1.5)
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "other", Help: "Not a cost"})
+	registry.MustRegister(monthly, hourly, other)
+
+	output := newTestRemoteWrite(t, &config.RemoteWriteConfig{
+		URL:            srv.URL,
+		ExternalLabels: map[string]string{"cluster": "production", "region": "global"},
+	}, registry)
+
+	before := time.Now()
+	if err := output.Write(context.Background()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if len(srv.requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(srv.requests))
+	}
+
+	series := make(map[string]writtenSeries)
+	for _, s := range srv.requests[0] {
+		series[s.labels["__name__"]] = s
+		if s.labels["cluster"] != "production" {
+			t.Errorf("%s cluster label = %q, want external label production", s.labels["__name__"], s.labels["cluster"])
+		}
+	}
+
+	monthlySeries := series["aws_monthly_cost"]
+	if monthlySeries.value != 42.5 || monthlySeries.labels["account_id"] != "123456789012" {
+		t.Errorf("aws_monthly_cost = %v %v, want 42.5 for account 123456789012", monthlySeries.value, monthlySeries.labels)
+	}
+	// Series labels take precedence over external labels
+	if monthlySeries.labels["region"] != "eu-west-1" {
+		t.Errorf("region label = %q, want series label eu-west-1", monthlySeries.labels["region"])
+	}
+	if want := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC); !monthlySeries.timestamp.Equal(want) {
+		t.Errorf("aws_monthly_cost timestamp = %s, want period start %s", monthlySeries.timestamp, want)
+	}
+
+	if want := time.Date(2025, time.March, 1, 8, 0, 0, 0, time.UTC); !series["aws_hourly_cost"].timestamp.Equal(want) {
+		t.Errorf("aws_hourly_cost timestamp = %s, want hour %s", series["aws_hourly_cost"].timestamp, want)
+	}
+
+	// Series without a cost period are timestamped when gathered
+	if ts := series["other"].timestamp; ts.Before(before.Truncate(time.Millisecond)) || ts.After(time.Now()) {
+		t.Errorf("other timestamp = %s, want the write time", ts)
+	}
+}
+
+func TestRemoteWriteOutputRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantRequests int
+		wantErr      bool
+	}{
+		{"server error retried", http.StatusInternalServerError, 2, false},
+		{"too many requests retried", http.StatusTooManyRequests, 2, false},
+		{"bad request not retried", http.StatusBadRequest, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			srv := newRemoteWriteServer(t, func([]writtenSeries) int {
+				calls++
+				if calls == 1 {
+					return tt.status
+				}
+				return http.StatusNoContent
+			})
+			registry, _ := newCostGauge(1)
+			output := newTestRemoteWrite(t, &config.RemoteWriteConfig{URL: srv.URL, MaxRetries: 3}, registry)
+
+			err := output.Write(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Write() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(srv.requests) != tt.wantRequests {
+				t.Errorf("received %d requests, want %d", len(srv.requests), tt.wantRequests)
+			}
+			// Rejected requests are not queued
+			if len(output.queue) != 0 {
+				t.Errorf("queue holds %d requests, want none", len(output.queue))
+			}
+		})
+	}
+}
+
+func TestRemoteWriteOutputQueue(t *testing.T) {
+	srv := newRemoteWriteServer(t, respondStatus(http.StatusServiceUnavailable))
+	registry, gauge := newCostGauge(1)
+	output := newTestRemoteWrite(t, &config.RemoteWriteConfig{URL: srv.URL, QueueCapacity: 2}, registry)
+	// Fail without retrying
+	output.maxRetries = 0
+
+	// Failed requests are queued, the oldest dropped once the queue is full
+	for value := 1.0; value <= 3; value++ {
+		gauge.Set(value)
+		if err := output.Write(context.Background()); err == nil {
+			t.Fatalf("Write() of %v succeeded, want error", value)
+		}
+	}
+	if len(output.queue) != 2 {
+		t.Fatalf("queue holds %d requests, want 2", len(output.queue))
+	}
+
+	// The new request overflows the queue again, dropping 2
+	srv.reset(respondStatus(http.StatusNoContent))
+	gauge.Set(4)
+	if err := output.Write(context.Background()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got, want := srv.values(), []float64{3, 4}; !slices.Equal(got, want) {
+		t.Errorf("sent values = %v, want %v", got, want)
+	}
+}
+
+func TestRemoteWriteOutputDrainsRejectedRequests(t *testing.T) {
+	srv := newRemoteWriteServer(t, respondStatus(http.StatusServiceUnavailable))
+	registry, gauge := newCostGauge(1)
+	output := newTestRemoteWrite(t, &config.RemoteWriteConfig{URL: srv.URL}, registry)
+	// Fail without retrying
+	output.maxRetries = 0
+
+	if err := output.Write(context.Background()); err == nil {
+		t.Fatal("Write() succeeded, want error")
+	}
+
+	// The queued request is now rejected, the new one still sent
+	srv.reset(func(series []writtenSeries) int {
+		if series[0].value == 1 {
+			return http.StatusBadRequest
+		}
+		return http.StatusNoContent
+	})
+	gauge.Set(2)
+	if err := output.Write(context.Background()); err == nil {
+		t.Error("Write() succeeded, want the rejection error")
+	}
+	if got, want := srv.values(), []float64{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("sent values = %v, want %v", got, want)
+	}
+	if len(output.queue) != 0 {
+		t.Errorf("queue holds %d requests, want none", len(output.queue))
+	}
+}
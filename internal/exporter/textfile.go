@@ -0,0 +1,36 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/config"
+)
+
+// TextfileOutput writes the metrics in Prometheus text format to a file, for
+// the node_exporter textfile collector. The file is written to a temporary
+// file first and renamed, so readers never see a partial file.
+type TextfileOutput struct {
+	path     string
+	gatherer prometheus.Gatherer
+}
+
+func NewTextfileOutput(cfg *config.TextfileConfig, gatherer prometheus.Gatherer) *TextfileOutput {
+	return &TextfileOutput{
+		path:     cfg.Path,
+		gatherer: gatherer,
+	}
+}
+
+func (o *TextfileOutput) Name() string {
+	return "textfile"
+}
+
+func (o *TextfileOutput) Write(ctx context.Context) error {
+	if err := prometheus.WriteToTextfile(o.path, o.gatherer); err != nil {
+		return fmt.Errorf("writing %s: %w", o.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// fixedColumns are written before the label columns, amount, unit and
+// estimated after them.
+var fixedColumns = []string{"account_id", "metric", "period_start", "period_end"}
+
+// ContentType returns the MIME type of a report format.
+func ContentType(format string) string {
+	if format == FormatParquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv"
+}
+
+// Write renders the cost entries with one row per account, metric, group and
+// period. Every label of the entries gets its own column, empty for metrics
+// not having it.
+func Write(w io.Writer, format string, entries []collector.CostEntry) error {
+	labels := labelColumns(entries)
+
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, labels, entries)
+	case FormatParquet:
+		return writeParquet(w, labels, entries)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+// labelColumns returns the sorted label names of all entries
+func labelColumns(entries []collector.CostEntry) []string {
+	reserved := map[string]bool{"amount": true, "unit": true, "estimated": true}
+	for _, name := range fixedColumns {
+		reserved[name] = true
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		for name := range entry.Labels {
+			if !seen[name] && !reserved[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeCSV(w io.Writer, labels []string, entries []collector.CostEntry) error {
+	cw := csv.NewWriter(w)
+
+	header := append(append(append([]string{}, fixedColumns...), labels...), "amount", "unit", "estimated")
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	for _, entry := range entries {
+		row := []string{
+			entry.AccountID,
+			entry.Metric,
+			formatTime(entry.Period.Start),
+			formatTime(entry.Period.End),
+		}
+		for _, name := range labels {
+			row = append(row, entry.Labels[name])
+		}
+		row = append(row,
+			strconv.FormatFloat(entry.Amount, 'f', -1, 64),
+			entry.Unit,
+			strconv.FormatBool(entry.Estimated))
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	return nil
+}
+
+func writeParquet(w io.Writer, labels []string, entries []collector.CostEntry) error {
+	group := parquet.Group{
+		"account_id":   parquet.String(),
+		"metric":       parquet.String(),
+		"period_start": parquet.Timestamp(parquet.Millisecond),
+		"period_end":   parquet.Timestamp(parquet.Millisecond),
+		"amount":       parquet.Leaf(parquet.DoubleType),
+		"unit":         parquet.String(),
+		"estimated":    parquet.Leaf(parquet.BooleanType),
+	}
+	for _, name := range labels {
+		group[name] = parquet.Optional(parquet.String())
+	}
+
+	pw := parquet.NewWriter(w, parquet.NewSchema("cost", group))
+	for _, entry := range entries {
+		row := map[string]any{
+			"account_id":   entry.AccountID,
+			"metric":       entry.Metric,
+			"period_start": entry.Period.Start,
+			"period_end":   entry.Period.End,
+			"amount":       entry.Amount,
+			"unit":         entry.Unit,
+			"estimated":    entry.Estimated,
+		}
+		for _, name := range labels {
+			if value, ok := entry.Labels[name]; ok {
+				row[name] = value
+			}
+		}
+		if err := pw.Write(row); err != nil {
+			return fmt.Errorf("writing parquet: %w", err)
+		}
+	}
+
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("writing parquet: %w", err)
+	}
+	return nil
+}
+
+// formatTime returns a date for midnight times and a timestamp otherwise
+func formatTime(t time.Time) string {
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.RFC3339)
+}
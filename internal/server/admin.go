@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+)
+
+// Refresher triggers an out of band refresh of the given accounts and
+// metrics, all of them when empty.
+type Refresher interface {
+	Refresh(ctx context.Context, accountIDs, metricNames []string) error
+}
+
+// RateLimitError is returned by a Refresher called too soon after the
+// previous refresh.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("refresh rate limited, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+type refreshResponse struct {
+	Status   string   `json:"status"`
+	Accounts []string `json:"accounts,omitempty"`
+	Metrics  []string `json:"metrics,omitempty"`
+	Duration float64  `json:"duration_seconds"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// RefreshHandler triggers a refresh on POST, optionally scoped with the
+// repeatable account and metric query parameters. When token is set,
+// requests must carry it as bearer token.
+func RefreshHandler(refresher Refresher, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if token != "" {
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		resp := refreshResponse{
+			Status:   "success",
+			Accounts: r.URL.Query()["account"],
+			Metrics:  r.URL.Query()["metric"],
+		}
+
+		// Refreshes outlast the server write timeout
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		start := time.Now()
+		err := refresher.Refresh(r.Context(), resp.Accounts, resp.Metrics)
+		resp.Duration = time.Since(start).Seconds()
+
+		status := http.StatusOK
+		if err != nil {
+			resp.Status = "error"
+			resp.Error = err.Error()
+
+			var rateLimit *RateLimitError
+			switch {
+			case errors.As(err, &rateLimit):
+				status = http.StatusTooManyRequests
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateLimit.RetryAfter.Seconds()))))
+			case errors.Is(err, collector.ErrUnknownScope):
+				status = http.StatusBadRequest
+			default:
+				status = http.StatusInternalServerError
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/collector"
+	"github.com/ydelafollye/aws-cost-exporter-go/internal/report"
+)
+
+// CostSource provides the latest cost entries
+type CostSource interface {
+	Costs() []collector.CostEntry
+}
+
+type costResponse struct {
+	Data []costEntry `json:"data"`
+}
+
+type costEntry struct {
+	AccountID   string            `json:"account_id"`
+	Metric      string            `json:"metric"`
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Keys        []string          `json:"keys"`
+	Labels      map[string]string `json:"labels"`
+	Amount      float64           `json:"amount"`
+	Unit        string            `json:"unit"`
+	Estimated   bool              `json:"estimated"`
+	FetchedAt   time.Time         `json:"fetched_at"`
+}
+
+// costFilter selects entries from the request query. Each parameter may be
+// repeated, values of a same parameter are ORed and parameters ANDed:
+//
+//	/api/v1/costs?account=123456789012&metric=aws_daily_cost&label=ServiceName=AWS+Lambda
+type costFilter struct {
+	accounts map[string]bool
+	metrics  map[string]bool
+	labels   map[string]map[string]bool
+}
+
+func parseCostFilter(r *http.Request) (*costFilter, error) {
+	query := r.URL.Query()
+	f := &costFilter{
+		accounts: toSet(query["account"]),
+		metrics:  toSet(query["metric"]),
+		labels:   make(map[string]map[string]bool),
+	}
+
+	for _, label := range query["label"] {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("label filter must be name=value, got %q", label)
+		}
+		if f.labels[name] == nil {
+			f.labels[name] = make(map[string]bool)
+		}
+		f.labels[name][value] = true
+	}
+
+	return f, nil
+}
+
+func (f *costFilter) match(entry collector.CostEntry) bool {
+	if len(f.accounts) > 0 && !f.accounts[entry.AccountID] {
+		return false
+	}
+	if len(f.metrics) > 0 && !f.metrics[entry.Metric] {
+		return false
+	}
+	for name, values := range f.labels {
+		if !values[entry.Labels[name]] {
+			return false
+		}
+	}
+	return true
+}
+
+// apply returns the matching entries sorted by account and metric
+func (f *costFilter) apply(entries []collector.CostEntry) []collector.CostEntry {
+	var matching []collector.CostEntry
+	for _, entry := range entries {
+		if f.match(entry) {
+			matching = append(matching, entry)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b collector.CostEntry) int {
+		return cmp.Or(cmp.Compare(a.AccountID, b.AccountID), cmp.Compare(a.Metric, b.Metric))
+	})
+	return matching
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// CostsHandler serves the latest cost entries as JSON
+func CostsHandler(source CostSource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filter, err := parseCostFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := costResponse{Data: []costEntry{}}
+		for _, entry := range filter.apply(source.Costs()) {
+			resp.Data = append(resp.Data, costEntry{
+				AccountID:   entry.AccountID,
+				Metric:      entry.Metric,
+				PeriodStart: entry.Period.Start,
+				PeriodEnd:   entry.Period.End,
+				Keys:        entry.Keys,
+				Labels:      entry.Labels,
+				Amount:      entry.Amount,
+				Unit:        entry.Unit,
+				Estimated:   entry.Estimated,
+				FetchedAt:   entry.FetchedAt,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// CostsExportHandler serves the latest cost entries as a CSV or Parquet file,
+// selected by the format query parameter, with the same filters as
+// CostsHandler.
+func CostsExportHandler(source CostSource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = report.FormatCSV
+		}
+		if format != report.FormatCSV && format != report.FormatParquet {
+			http.Error(w, fmt.Sprintf("unsupported format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := parseCostFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", report.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"costs.%s\"", format))
+		_ = report.Write(w, format, filter.apply(source.Costs()))
+	})
+}
@@ -2,54 +2,131 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
 type Server struct {
 	httpServer *http.Server
+	mux        *http.ServeMux
+	options    Options
 	logger     *slog.Logger
 }
 
-func New(port int, logger *slog.Logger) *Server {
-	mux := http.NewServeMux()
+// Options configures the HTTP server. Zero values use the defaults, except
+// for Gatherer which is required.
+type Options struct {
+	// Gatherer provides the metrics served on MetricsPath
+	Gatherer prometheus.Gatherer
+	// ListenAddress is a TCP address, IPv6 addresses in brackets, or a Unix
+	// socket as unix:///path/to/socket
+	ListenAddress string
+	MetricsPath   string
+	// RoutePrefix is prepended to every endpoint, to run behind a reverse
+	// proxy
+	RoutePrefix   string
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	SystemdSocket bool
+	// WebConfigFile is an optional exporter-toolkit web config file enabling
+	// TLS and basic authentication on all endpoints
+	WebConfigFile string
+}
+
+func New(opts Options, logger *slog.Logger) *Server {
+	if opts.MetricsPath == "" {
+		opts.MetricsPath = "/metrics"
+	}
+	opts.RoutePrefix = strings.TrimSuffix(opts.RoutePrefix, "/")
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+
+	s := &Server{
+		mux:     http.NewServeMux(),
+		options: opts,
+		logger:  logger,
+	}
 
 	// Expose Endpoints
-	mux.Handle("/metrics", promhttp.Handler())
+	s.Handle(opts.MetricsPath, promhttp.HandlerFor(opts.Gatherer, promhttp.HandlerOpts{}))
 
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+	s.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
-	})
+	}))
 
-	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+	s.Handle("/readyz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
-	})
+	}))
 
-	return &Server{
-		httpServer: &http.Server{
-			Addr:         fmt.Sprintf(":%d", port),
-			Handler:      mux,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
-		},
-		logger: logger,
+	s.httpServer = &http.Server{
+		Addr:         opts.ListenAddress,
+		Handler:      s.mux,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
 	}
+
+	return s
+}
+
+// Handle registers an additional endpoint under the route prefix, before the
+// server is started.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(s.options.RoutePrefix+pattern, handler)
 }
 
 func (s *Server) Start() error {
-	s.logger.Info("starting HTTP server", "addr", s.httpServer.Addr)
-	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+	s.logger.Info("starting HTTP server",
+		"addr", s.options.ListenAddress,
+		"systemd_socket", s.options.SystemdSocket,
+		"metrics_path", s.options.RoutePrefix+s.options.MetricsPath)
+
+	flags := &web.FlagConfig{
+		WebListenAddresses: &[]string{s.options.ListenAddress},
+		WebSystemdSocket:   &s.options.SystemdSocket,
+		WebConfigFile:      &s.options.WebConfigFile,
+	}
+
+	var err error
+	if path, ok := strings.CutPrefix(s.options.ListenAddress, "unix://"); ok && !s.options.SystemdSocket {
+		err = s.serveUnix(path, flags)
+	} else {
+		err = web.ListenAndServe(s.httpServer, flags, s.logger)
+	}
+	if err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// serveUnix serves on a Unix socket, removing a stale socket file first
+func (s *Server) serveUnix(path string, flags *web.FlagConfig) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return web.Serve(listener, s.httpServer, flags, s.logger)
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("shutting down HTTP server")
 	return s.httpServer.Shutdown(ctx)
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// WebAuth describes the client authentication enforced by a web config file
+type WebAuth struct {
+	// BasicAuth is set when basic_auth_users is defined
+	BasicAuth bool
+	// ClientCerts is set when clients must present a certificate
+	ClientCerts bool
+}
+
+// Enabled reports whether clients must authenticate
+func (a WebAuth) Enabled() bool {
+	return a.BasicAuth || a.ClientCerts
+}
+
+// ReadWebAuth returns the client authentication enforced by an exporter-toolkit
+// web config file. The file is expected to be valid, see web.Validate.
+func ReadWebAuth(path string) (WebAuth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return WebAuth{}, fmt.Errorf("reading web config: %w", err)
+	}
+
+	var webConfig struct {
+		TLSServerConfig struct {
+			ClientAuthType string `yaml:"client_auth_type"`
+		} `yaml:"tls_server_config"`
+		BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+	}
+	if err := yaml.Unmarshal(data, &webConfig); err != nil {
+		return WebAuth{}, fmt.Errorf("parsing web config: %w", err)
+	}
+
+	clientAuth := webConfig.TLSServerConfig.ClientAuthType
+	return WebAuth{
+		BasicAuth:   len(webConfig.BasicAuthUsers) > 0,
+		ClientCerts: clientAuth == "RequireAnyClientCert" || clientAuth == "RequireAndVerifyClientCert",
+	}, nil
+}
@@ -2,25 +2,125 @@ package timeutil
 
 import "time"
 
+// Clock provides the current time used to compute periods.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock returning the wall clock time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+type fixedClock struct {
+	t time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.t
+}
+
+// FixedClock returns a Clock always returning t.
+func FixedClock(t time.Time) Clock {
+	return fixedClock{t: t}
+}
+
 type Period struct {
 	Start time.Time
 	End   time.Time
 }
 
-func DailyPeriod(delayDays int) Period {
-	now := time.Now().UTC()
+// Empty reports whether the period contains no day, e.g. a month to date
+// period ending on the first of the month.
+func (p Period) Empty() bool {
+	return !p.End.After(p.Start)
+}
+
+// endDate returns the clock's current date at midnight UTC shifted back by delayDays.
+// It is used as the exclusive end of every period.
+func endDate(clock Clock, delayDays int) time.Time {
+	now := clock.Now().UTC()
 	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return end.AddDate(0, 0, -delayDays)
+}
+
+// HourlyPeriod returns the last 24 full hours, shifted back by delayDays.
+func HourlyPeriod(clock Clock, delayDays int) Period {
+	end := clock.Now().UTC().Truncate(time.Hour)
 	end = end.AddDate(0, 0, -delayDays)
+	start := end.Add(-24 * time.Hour)
+
+	return Period{Start: start, End: end}
+}
+
+func DailyPeriod(clock Clock, delayDays int) Period {
+	end := endDate(clock, delayDays)
 	start := end.AddDate(0, 0, -1)
 
 	return Period{Start: start, End: end}
 }
 
-func MonthlyPeriod(delayDays int) Period {
-	now := time.Now().UTC()
-	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-	end = end.AddDate(0, 0, -delayDays)
+func MonthlyPeriod(clock Clock, delayDays int) Period {
+	end := endDate(clock, delayDays)
 	start := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
 
 	return Period{Start: start, End: end}
 }
+
+// PreviousMonthPeriod returns the last full calendar month before end.
+func PreviousMonthPeriod(clock Clock, delayDays int) Period {
+	end := endDate(clock, delayDays)
+	end = time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, -1, 0)
+
+	return Period{Start: start, End: end}
+}
+
+// RollingPeriod returns the last days days before end.
+func RollingPeriod(clock Clock, days, delayDays int) Period {
+	end := endDate(clock, delayDays)
+	start := end.AddDate(0, 0, -days)
+
+	return Period{Start: start, End: end}
+}
+
+// WeekToDatePeriod returns the period from the Monday of the ISO week
+// containing end.
+func WeekToDatePeriod(clock Clock, delayDays int) Period {
+	end := endDate(clock, delayDays)
+	// time.Weekday starts on Sunday, ISO weeks start on Monday
+	offset := (int(end.Weekday()) + 6) % 7
+	start := end.AddDate(0, 0, -offset)
+
+	return Period{Start: start, End: end}
+}
+
+// QuarterToDatePeriod returns the period from the first day of the calendar
+// quarter containing end.
+func QuarterToDatePeriod(clock Clock, delayDays int) Period {
+	end := endDate(clock, delayDays)
+	firstMonth := time.Month((int(end.Month())-1)/3*3 + 1)
+	start := time.Date(end.Year(), firstMonth, 1, 0, 0, 0, 0, time.UTC)
+
+	return Period{Start: start, End: end}
+}
+
+// YearToDatePeriod returns the period from January 1st of end's year.
+func YearToDatePeriod(clock Clock, delayDays int) Period {
+	return FiscalYearToDatePeriod(clock, time.January, delayDays)
+}
+
+// FiscalYearToDatePeriod returns the period from the start of the fiscal
+// year containing end, fiscal years starting on the first day of startMonth.
+func FiscalYearToDatePeriod(clock Clock, startMonth time.Month, delayDays int) Period {
+	end := endDate(clock, delayDays)
+	year := end.Year()
+	if end.Month() < startMonth {
+		year--
+	}
+	start := time.Date(year, startMonth, 1, 0, 0, 0, 0, time.UTC)
+
+	return Period{Start: start, End: end}
+}
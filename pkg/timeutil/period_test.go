@@ -0,0 +1,238 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPeriodEmpty(t *testing.T) {
+	tests := []struct {
+		name   string
+		period Period
+		want   bool
+	}{
+		{"one day", Period{Start: date(2025, time.March, 1), End: date(2025, time.March, 2)}, false},
+		{"same day", Period{Start: date(2025, time.March, 1), End: date(2025, time.March, 1)}, true},
+		{"end before start", Period{Start: date(2025, time.March, 2), End: date(2025, time.March, 1)}, true},
+		{"zero", Period{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.period.Empty(); got != tt.want {
+				t.Errorf("Empty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthlyPeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		now       time.Time
+		delayDays int
+		want      Period
+		wantEmpty bool
+	}{
+		{
+			name:      "first of month",
+			now:       time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC),
+			want:      Period{Start: date(2025, time.March, 1), End: date(2025, time.March, 1)},
+			wantEmpty: true,
+		},
+		{
+			name: "second of month",
+			now:  time.Date(2025, time.March, 2, 10, 0, 0, 0, time.UTC),
+			want: Period{Start: date(2025, time.March, 1), End: date(2025, time.March, 2)},
+		},
+		{
+			name:      "delay crossing the month",
+			now:       time.Date(2025, time.March, 2, 10, 0, 0, 0, time.UTC),
+			delayDays: 1,
+			want:      Period{Start: date(2025, time.March, 1), End: date(2025, time.March, 1)},
+			wantEmpty: true,
+		},
+		{
+			name:      "delay crossing the year",
+			now:       time.Date(2025, time.January, 3, 10, 0, 0, 0, time.UTC),
+			delayDays: 3,
+			want:      Period{Start: date(2024, time.December, 1), End: date(2024, time.December, 31)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MonthlyPeriod(FixedClock(tt.now), tt.delayDays)
+			if got != tt.want {
+				t.Errorf("MonthlyPeriod() = %v, want %v", got, tt.want)
+			}
+			if got.Empty() != tt.wantEmpty {
+				t.Errorf("Empty() = %v, want %v", got.Empty(), tt.wantEmpty)
+			}
+		})
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	tests := []struct {
+		name      string
+		now       time.Time
+		period    func(Clock) Period
+		want      Period
+		wantEmpty bool
+	}{
+		{
+			name:   "previous month in January",
+			now:    time.Date(2025, time.January, 15, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return PreviousMonthPeriod(c, 0) },
+			want:   Period{Start: date(2024, time.December, 1), End: date(2025, time.January, 1)},
+		},
+		{
+			name:   "previous month on January 1st",
+			now:    time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return PreviousMonthPeriod(c, 0) },
+			want:   Period{Start: date(2024, time.December, 1), End: date(2025, time.January, 1)},
+		},
+		{
+			name:   "previous month with delay crossing January",
+			now:    time.Date(2025, time.February, 1, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return PreviousMonthPeriod(c, 1) },
+			want:   Period{Start: date(2024, time.December, 1), End: date(2025, time.January, 1)},
+		},
+		{
+			name:      "quarter to date on the first day of the quarter",
+			now:       time.Date(2025, time.April, 1, 10, 0, 0, 0, time.UTC),
+			period:    func(c Clock) Period { return QuarterToDatePeriod(c, 0) },
+			want:      Period{Start: date(2025, time.April, 1), End: date(2025, time.April, 1)},
+			wantEmpty: true,
+		},
+		{
+			name:   "quarter to date on the last day of the quarter",
+			now:    time.Date(2025, time.June, 30, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return QuarterToDatePeriod(c, 0) },
+			want:   Period{Start: date(2025, time.April, 1), End: date(2025, time.June, 30)},
+		},
+		{
+			name:   "quarter to date with delay crossing the year",
+			now:    time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return QuarterToDatePeriod(c, 1) },
+			want:   Period{Start: date(2024, time.October, 1), End: date(2024, time.December, 31)},
+		},
+		{
+			name:      "year to date on January 1st",
+			now:       time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC),
+			period:    func(c Clock) Period { return YearToDatePeriod(c, 0) },
+			want:      Period{Start: date(2025, time.January, 1), End: date(2025, time.January, 1)},
+			wantEmpty: true,
+		},
+		{
+			name:   "year to date on January 2nd",
+			now:    time.Date(2025, time.January, 2, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return YearToDatePeriod(c, 0) },
+			want:   Period{Start: date(2025, time.January, 1), End: date(2025, time.January, 2)},
+		},
+		{
+			name:      "week to date on Monday",
+			now:       time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC),
+			period:    func(c Clock) Period { return WeekToDatePeriod(c, 0) },
+			want:      Period{Start: date(2025, time.March, 3), End: date(2025, time.March, 3)},
+			wantEmpty: true,
+		},
+		{
+			name:   "week to date on Sunday",
+			now:    time.Date(2025, time.March, 9, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return WeekToDatePeriod(c, 0) },
+			want:   Period{Start: date(2025, time.March, 3), End: date(2025, time.March, 9)},
+		},
+		{
+			name:   "week to date across the year",
+			now:    time.Date(2025, time.January, 2, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return WeekToDatePeriod(c, 0) },
+			want:   Period{Start: date(2024, time.December, 30), End: date(2025, time.January, 2)},
+		},
+		{
+			name:   "fiscal year to date before the start month",
+			now:    time.Date(2025, time.March, 15, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return FiscalYearToDatePeriod(c, time.April, 0) },
+			want:   Period{Start: date(2024, time.April, 1), End: date(2025, time.March, 15)},
+		},
+		{
+			name:      "fiscal year to date on the first day",
+			now:       time.Date(2025, time.April, 1, 10, 0, 0, 0, time.UTC),
+			period:    func(c Clock) Period { return FiscalYearToDatePeriod(c, time.April, 0) },
+			want:      Period{Start: date(2025, time.April, 1), End: date(2025, time.April, 1)},
+			wantEmpty: true,
+		},
+		{
+			name:   "fiscal year to date after the start month",
+			now:    time.Date(2025, time.October, 15, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return FiscalYearToDatePeriod(c, time.April, 0) },
+			want:   Period{Start: date(2025, time.April, 1), End: date(2025, time.October, 15)},
+		},
+		{
+			name:   "fiscal year to date with delay before the start month",
+			now:    time.Date(2025, time.April, 1, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return FiscalYearToDatePeriod(c, time.April, 1) },
+			want:   Period{Start: date(2024, time.April, 1), End: date(2025, time.March, 31)},
+		},
+		{
+			name:   "last 7 days across the year",
+			now:    time.Date(2025, time.January, 3, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return RollingPeriod(c, 7, 0) },
+			want:   Period{Start: date(2024, time.December, 27), End: date(2025, time.January, 3)},
+		},
+		{
+			name:   "hourly truncated to the hour",
+			now:    time.Date(2025, time.January, 1, 10, 35, 0, 0, time.UTC),
+			period: func(c Clock) Period { return HourlyPeriod(c, 0) },
+			want:   Period{Start: time.Date(2024, time.December, 31, 10, 0, 0, 0, time.UTC), End: time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "hourly with delay",
+			now:    time.Date(2025, time.March, 1, 10, 35, 0, 0, time.UTC),
+			period: func(c Clock) Period { return HourlyPeriod(c, 2) },
+			want:   Period{Start: time.Date(2025, time.February, 26, 10, 0, 0, 0, time.UTC), End: time.Date(2025, time.February, 27, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "hourly in another time zone",
+			now:    time.Date(2025, time.March, 1, 10, 35, 0, 0, time.FixedZone("UTC+5:30", 5*3600+1800)),
+			period: func(c Clock) Period { return HourlyPeriod(c, 0) },
+			want:   Period{Start: time.Date(2025, time.February, 28, 5, 0, 0, 0, time.UTC), End: time.Date(2025, time.March, 1, 5, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "daily on January 1st",
+			now:    time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC),
+			period: func(c Clock) Period { return DailyPeriod(c, 0) },
+			want:   Period{Start: date(2024, time.December, 31), End: date(2025, time.January, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.period(FixedClock(tt.now))
+			if got != tt.want {
+				t.Errorf("period = %v, want %v", got, tt.want)
+			}
+			if got.Empty() != tt.wantEmpty {
+				t.Errorf("Empty() = %v, want %v", got.Empty(), tt.wantEmpty)
+			}
+		})
+	}
+}
+
+func TestWeekToDatePeriodEmptyOnMondays(t *testing.T) {
+	for day := date(2024, time.December, 1); day.Year() < 2026; day = day.AddDate(0, 0, 1) {
+		now := day.Add(10 * time.Hour)
+		got := WeekToDatePeriod(FixedClock(now), 0)
+		if monday := day.Weekday() == time.Monday; got.Empty() != monday {
+			t.Errorf("WeekToDatePeriod(%s).Empty() = %v, want %v", now.Format(time.DateOnly), got.Empty(), monday)
+		}
+		if got.Start.Weekday() != time.Monday {
+			t.Errorf("WeekToDatePeriod(%s) starts on %s, want Monday", now.Format(time.DateOnly), got.Start.Weekday())
+		}
+	}
+}